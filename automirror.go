@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpandAutoMirrors queries the source forge of every [[AutoMirror]] block and appends a synthesized GGMirror for every found repository
+// Returns the target urls of all repositories that were created on the target forge
+// If verbose is set every repository that was excluded by a filter is listed together with the reason
+func (this *GGMConfig) ExpandAutoMirrors(verbose bool) []string {
+	created := make([]string, 0)
+
+	for _, am := range this.AutoMirror {
+		source := am.Source.Provider()
+		target := am.Target.Provider()
+
+		LOG_OUT("Listing repositories of " + am.Source.Username + " on " + am.Source.RootURL)
+
+		repos, err := source.ListRepositories()
+		if err != nil {
+			EXIT_ERROR("ERROR: Failed to list repositories of '"+am.Source.Username+"' on "+am.Source.RootURL+"\n\n"+err.Error(), EXIT_FORGE_API_ERROR)
+		}
+
+		LOG_OUT("Found " + strconv.Itoa(len(repos)) + " repositories")
+
+		filtered := 0
+
+		for _, repo := range repos {
+			if ok, reason := am.FilterRepository(repo); !ok {
+				filtered++
+				if verbose {
+					LOG_OUT("Filtered " + repo.Name + " (" + reason + ")")
+				}
+				continue
+			}
+
+			if repo.CloneURL == "" {
+				LOG_OUT("WARNING: Skip " + repo.Name + " (no https clone url found)")
+				continue
+			}
+
+			mirror := GGMirror{
+				ID:                "auto:" + am.Source.Username + "/" + repo.Name,
+				Source:            repo.CloneURL,
+				Target:            target.GetCloneURL(repo.Name),
+				SourceCredentials: am.Source.Credentials,
+				TargetCredentials: am.Target.Credentials,
+				PrimaryBranch:     repo.DefaultBranch,
+			}
+
+			if mirror.PrimaryBranch == "" {
+				mirror.PrimaryBranch, err = source.GetDefaultBranch(repo.Name)
+				if err != nil {
+					LOG_OUT("WARNING: Cannot query default branch of " + repo.Name + ": " + err.Error())
+				}
+			}
+
+			if err := am.ApplyBranchRules(&mirror); err != nil {
+				LOG_OUT("WARNING: Skip " + repo.Name + " (" + err.Error() + ")")
+				continue
+			}
+
+			// a source can be mirrored to several targets, only the target must not be used by another remote
+			if other := this.FindRemoteByTarget(mirror.Target); other != nil {
+				LOG_OUT("Skip " + repo.Name + " (the target is already configured in the [[Remote]] of " + other.Source + ")")
+				continue
+			}
+
+			if am.CreateTargets {
+				existing, err := target.GetRepository(repo.Name)
+				if err != nil {
+					EXIT_ERROR("ERROR: Failed to query repository '"+repo.Name+"' on "+am.Target.RootURL+"\n\n"+err.Error(), EXIT_FORGE_API_ERROR)
+				}
+
+				if existing == nil {
+					LOG_OUT("Creating repository " + mirror.Target)
+					err = target.CreateRepository(repo.Name, MirrorDescription(repo), am.TargetVisibility)
+					if err != nil {
+						EXIT_ERROR("ERROR: Failed to create repository '"+repo.Name+"' on "+am.Target.RootURL+"\n\n"+err.Error(), EXIT_FORGE_API_ERROR)
+					}
+					created = append(created, mirror.Target)
+				}
+			}
+
+			this.InitRemote(&mirror)
+			this.Remote = append(this.Remote, mirror)
+
+			LOG_OUT("   > " + mirror.Source + " -> " + mirror.Target)
+		}
+
+		if filtered > 0 && !verbose {
+			LOG_OUT("Filtered " + strconv.Itoa(filtered) + " repositories (use --verbose to list them)")
+		}
+
+		LOG_LINESEP()
+	}
+
+	return created
+}
+
+// ApplyBranchRules sets the branch selection of a synthesized mirror (PrimaryBranch must already be set)
+// Returns an error if the rules cannot be applied, the repository must then be skipped (instead of mirroring all branches)
+func (this GGAutoMirror) ApplyBranchRules(mirror *GGMirror) error {
+	if this.OnlyDefaultBranch {
+		if mirror.PrimaryBranch == "" {
+			return errors.New("OnlyDefaultBranch is set, but the default branch is unknown")
+		}
+		mirror.Branches = []string{mirror.PrimaryBranch}
+	} else if this.Branches != nil {
+		mirror.Branches = this.Branches
+	} else if this.BranchPatterns != nil {
+		mirror.IncludeBranches = this.BranchPatterns
+	}
+	return nil
+}
+
+// FilterRepository returns false (and the reason) if the repository should not be mirrored
+func (this GGAutoMirror) FilterRepository(repo ForgeRepository) (bool, string) {
+	if len(this.Include) > 0 {
+		if _, ok := MatchAnyPattern(this.Include, repo.Name); !ok {
+			return false, "name does not match any Include pattern"
+		}
+	}
+
+	if pattern, ok := MatchAnyPattern(this.Exclude, repo.Name); ok {
+		return false, "name matches Exclude pattern '" + pattern + "'"
+	}
+
+	if this.SkipForks && repo.Fork {
+		return false, "is a fork"
+	}
+
+	if this.SkipArchived && repo.Archived {
+		return false, "is archived"
+	}
+
+	if this.SkipPrivate && repo.Private {
+		return false, "is private"
+	}
+
+	if this.SkipPublic && !repo.Private {
+		return false, "is public"
+	}
+
+	if len(this.Topics) > 0 {
+		found := false
+		for _, topic := range repo.Topics {
+			for _, wanted := range this.Topics {
+				if strings.EqualFold(topic, wanted) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false, "has none of the topics [" + strings.Join(this.Topics, ", ") + "]"
+		}
+	}
+
+	if this.MinPushAgeDuration > 0 {
+		if repo.PushedAt.IsZero() {
+			return false, "last push time is unknown"
+		}
+		if age := time.Since(repo.PushedAt); age < this.MinPushAgeDuration {
+			return false, "last push was only " + age.Round(time.Minute).String() + " ago"
+		}
+	}
+
+	return true, ""
+}
+
+// MirrorDescription returns the description of the source repository, marked as a mirror
+func MirrorDescription(repo ForgeRepository) string {
+	if IsEmpty(repo.Description) {
+		return "[mirror] Mirror of " + repo.CloneURL
+	}
+	return "[mirror] " + strings.TrimSpace(repo.Description)
+}
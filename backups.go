@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type BackupMode string
+
+const (
+	BackupModeNone   BackupMode = "none"   // no backups (DivergencePolicy = backup still uses ref)
+	BackupModeRef    BackupMode = "ref"    // push the old tip to <BackupNamespace>/<date>/<branch> in the target
+	BackupModeBundle BackupMode = "bundle" // write the old tip as a git bundle into BackupFolder
+)
+
+const backupTimeFormat = "20060102T150405Z"
+
+// BackupOptions configures where GitController.BackupTargetRef stores the old tip of a branch before it is overwritten
+type BackupOptions struct {
+	Mode      BackupMode
+	Namespace string // Mode == ref, e.g. refs/gogitmirror/backup
+	Folder    string // Mode == bundle, the bundle folder of this target
+
+	Retention time.Duration // Mode == bundle, older bundles are deleted (0 == keep forever)
+}
+
+// Backup is a backup of a branch found by ListBackups, ID is the ref in the target or the path of the bundle
+type Backup struct {
+	ID     string
+	Branch string
+	Time   time.Time
+	Sha    string
+}
+
+// GetBackupOptions returns the backup settings for the given target view
+func (this GGMConfig) GetBackupOptions(target GGMirror) BackupOptions {
+	return BackupOptions{
+		Mode:      this.ForceBackup,
+		Namespace: this.BackupNamespace,
+		Folder:    filepath.Join(ExpandPath(this.BackupFolder), GetFolderName(target.Target)),
+		Retention: this.BackupRetentionDuration,
+	}
+}
+
+// BackupTargetBranch saves the current tip of the target branch (origin) as a backup ref in the target or as a local bundle
+func (this *GitController) BackupTargetBranch(targetBranch string, remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool, backup BackupOptions) error {
+	return this.BackupTargetRef("refs/remotes/origin/"+targetBranch, targetBranch, remote, cred, credmode, forceNetRCClean, backup)
+}
+
+// BackupTargetRef saves trackingRef (the fetched state of a ref of the target) under name (the branch or, for other refs, the full ref)
+func (this *GitController) BackupTargetRef(trackingRef string, name string, remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool, backup BackupOptions) error {
+	targetSha := this.GetRefHash(trackingRef)
+	if targetSha == "" {
+		return errors.New("The ref " + name + " does not exist in the target")
+	}
+
+	timestamp := time.Now().UTC().Format(backupTimeFormat)
+
+	if backup.Mode == BackupModeBundle {
+		if err := os.MkdirAll(backup.Folder, 0777); err != nil {
+			return errors.New("Cannot create backup folder '" + backup.Folder + "'\n\n" + err.Error())
+		}
+
+		file := filepath.Join(backup.Folder, timestamp+"_"+url.PathEscape(name)+".bundle")
+
+		this.Log.Out("Backing up " + targetSha[0:8] + " to " + file)
+		if _, err := this.ExecGitCommand(false, "bundle", "create", file, trackingRef); err != nil {
+			return err
+		}
+
+		this.DeleteExpiredBundles(backup)
+		return nil
+	}
+
+	ref := backup.Namespace + "/" + timestamp + "/" + name
+
+	this.Log.Out("Backing up " + targetSha[0:8] + " to " + ref)
+	_, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "push", remote, targetSha+":"+ref)
+	return err
+}
+
+// KeepsRefs returns true if backups can be written as refs into the target, a pruning push must then exclude the namespace
+func (this BackupOptions) KeepsRefs(policy DivergencePolicy) bool {
+	return this.Mode == BackupModeRef || (this.Mode == BackupModeNone && policy == DivergenceBackup)
+}
+
+// BackupBranchRef returns the ref of a backed up branch (Backup.Branch is the full ref for refs that are not branches)
+func BackupBranchRef(branch string) string {
+	if strings.HasPrefix(branch, "refs/") {
+		return branch
+	}
+	return "refs/heads/" + branch
+}
+
+// DeleteExpiredBundles removes the bundles of the target that are older than the retention time
+func (this *GitController) DeleteExpiredBundles(backup BackupOptions) {
+	if backup.Retention <= 0 {
+		return
+	}
+
+	files, err := ioutil.ReadDir(backup.Folder)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".bundle") || time.Since(file.ModTime()) < backup.Retention {
+			continue
+		}
+
+		this.Log.Out("Deleting expired backup " + file.Name())
+		if err := os.Remove(filepath.Join(backup.Folder, file.Name())); err != nil {
+			this.Log.Out("WARNING: Cannot delete expired backup " + file.Name() + "\n\n" + err.Error())
+		}
+	}
+}
+
+// ListBackups returns the backup refs in the target and the bundles in the backup folder (oldest first)
+func (this GGMirror) ListBackups(config GGMConfig, repo GitController) ([]Backup, error) {
+	result := make([]Backup, 0)
+
+	stdout, err := repo.ExecCredGitCommand(this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC, this.TargetCredentials.NoSSLVerify, "ls-remote", this.Target, config.BackupNamespace+"/*")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(stdout, "\n") {
+		cols := strings.Fields(line)
+		if len(cols) != 2 {
+			continue
+		}
+
+		// <namespace>/<date>/<branch>
+		parts := strings.SplitN(strings.TrimPrefix(cols[1], config.BackupNamespace+"/"), "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		timestamp, err := time.Parse(backupTimeFormat, parts[0])
+		if err != nil {
+			continue
+		}
+
+		result = append(result, Backup{ID: cols[1], Branch: parts[1], Time: timestamp, Sha: cols[0]})
+	}
+
+	folder := config.GetBackupOptions(this).Folder
+
+	files, _ := ioutil.ReadDir(folder) // no folder == no bundles
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".bundle") {
+			continue
+		}
+
+		// <date>_<escaped branch>.bundle
+		parts := strings.SplitN(strings.TrimSuffix(file.Name(), ".bundle"), "_", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		timestamp, err := time.Parse(backupTimeFormat, parts[0])
+		if err != nil {
+			continue
+		}
+		branch, err := url.PathUnescape(parts[1])
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(folder, file.Name())
+
+		sha := ""
+		if heads, err := repo.ExecGitCommand(false, "bundle", "list-heads", path); err == nil && len(strings.Fields(heads)) > 0 {
+			sha = strings.Fields(heads)[0]
+		}
+
+		result = append(result, Backup{ID: path, Branch: branch, Time: timestamp, Sha: sha})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.Before(result[j].Time) })
+
+	return result, nil
+}
+
+// RestoreBackup force-pushes the backup (ref or bundle) to its branch in the target
+// The current tip of the branch is backed up first if ForceBackup is configured
+func (this GGMirror) RestoreBackup(config GGMConfig, backup Backup) error {
+	folder, err := ioutil.TempDir(ExpandPath(config.TemporaryPath), "gogitmirror_restore_")
+	if err != nil {
+		return errors.New("Cannot create temporary folder\n\n" + err.Error())
+	}
+	defer os.RemoveAll(folder)
+
+	repo := GitController{Folder: folder, Log: this.Log}
+	cred := this.TargetCredentials
+
+	if _, err := repo.ExecGitCommand(false, "init", "--bare"); err != nil {
+		return err
+	}
+	if err := repo.SetRemote("origin", this.Target); err != nil {
+		return err
+	}
+	if _, err := repo.ExecCredGitCommand(cred, config.CredentialMode, config.AlwaysCleanNetRC, cred.NoSSLVerify, "fetch", "--no-tags", "origin"); err != nil {
+		return err
+	}
+
+	const restoreRef = "refs/gogitmirror/restore"
+
+	if strings.HasSuffix(backup.ID, ".bundle") {
+		heads, err := repo.ExecGitCommand(false, "bundle", "list-heads", backup.ID)
+		if err != nil {
+			return err
+		}
+		cols := strings.Fields(heads)
+		if len(cols) < 2 {
+			return errors.New("The bundle " + backup.ID + " contains no ref")
+		}
+		if _, err := repo.ExecGitCommand(false, "fetch", backup.ID, "+"+cols[1]+":"+restoreRef); err != nil {
+			return err
+		}
+	} else {
+		if _, err := repo.ExecCredGitCommand(cred, config.CredentialMode, config.AlwaysCleanNetRC, cred.NoSSLVerify, "fetch", "origin", "+"+backup.ID+":"+restoreRef); err != nil {
+			return err
+		}
+	}
+
+	if config.ForceBackup != BackupModeNone && repo.GetRefHash("refs/remotes/origin/"+backup.Branch) != "" {
+		if lost, err := repo.CountLostCommits(restoreRef, backup.Branch); err == nil && lost > 0 {
+			if err := repo.BackupTargetBranch(backup.Branch, this.Target, cred, config.CredentialMode, config.AlwaysCleanNetRC, config.GetBackupOptions(this)); err != nil {
+				return err
+			}
+		}
+	}
+
+	this.Log.Out("Restoring branch " + backup.Branch + " to " + repo.GetRefHash(restoreRef))
+	stdout, err := repo.ExecCredGitCommand(cred, config.CredentialMode, config.AlwaysCleanNetRC, cred.NoSSLVerify, "push", "--force", "origin", restoreRef+":"+BackupBranchRef(backup.Branch))
+	this.Log.Out(stdout)
+	return err
+}
@@ -7,19 +7,29 @@ const EXIT_SUCCESS = 0
 const EXIT_CONFIG_READ_ERROR = 11
 const EXIT_FILESYSTEM_ACCESS_ERROR = 12
 const EXIT_CONFIG_VALUE_ERROR = 13
+const EXIT_CRYPT_ERROR = 14
+const EXIT_SECRET_ERROR = 15
 
 const EXIT_ERRONEOUS_ADD_ARGS = 21
 const EXIT_ERRONEOUS_CRYPT_ARGS = 22
 const EXIT_ERRONEOUS_CRED_ARGS = 23
 const EXIT_ERRONEOUS_SINGLE_ARGS = 24
 const EXIT_ERRONEOUS_SINGLE_ID = 25
+const EXIT_ERRONEOUS_CRON_ARGS = 26
+const EXIT_ERRONEOUS_LOCK_ARGS = 27
+const EXIT_ERRONEOUS_BACKUP_ARGS = 28
 
 const EXIT_GIT_ERROR = 31
+const EXIT_REMOTES_FAILED = 32
 
 const EXIT_CONFIG_WRITE = 41
 
 const EXIT_INIT_ERR = 51
 
+const EXIT_FORGE_API_ERROR = 61
+
+const EXIT_LOCKED = 71
+
 const EXIT_ERROR_INTERNAL = 99
 
 //----------------------------------------------------
@@ -32,7 +42,15 @@ const PROGVERSION = "0.8"
 const TEMPFOLDERNAME = "gogitmirror"
 const NETRCPATH = "~/.netrc"
 
-const SALT = "iBl0Vf3SPGq65m4X"
+const SALT = "iBl0Vf3SPGq65m4X" // only used to read legacy 'aes:' values
+
+const KEYFILE_PATH = "~/.config/gogitmirror.key"
+const ENV_CRYPT_KEY = "GOGITMIRROR_KEY"
+const ENV_CRYPT_KEYFILE = "GOGITMIRROR_KEYFILE"
+const ENV_CRYPT_NEWKEY = "GOGITMIRROR_NEW_KEY"
+const ENV_CRYPT_NEWKEYFILE = "GOGITMIRROR_NEW_KEYFILE"
+
+const ENV_ASKPASS_CRED = "GOGITMIRROR_ASKPASS_CRED"
 
 //----------------------------------------------------
 
@@ -41,6 +59,8 @@ const STAT_COL_BRANCH = 28
 const STAT_COL_SOURCE = 8
 const STAT_COL_LOCAL = 8
 const STAT_COL_TARGET = 8
+const STAT_COL_RESULT = 12
+const STAT_COL_DATE = 19
 
 //----------------------------------------------------
 
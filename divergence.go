@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+type DivergencePolicy string
+
+const (
+	DivergenceAbort  DivergencePolicy = "abort"  // don't force-push a branch if the target contains commits that are not in the source (default)
+	DivergenceBackup DivergencePolicy = "backup" // back up the old target tip (see ForceBackup, default is a ref in the target), then force-push
+	DivergenceForce  DivergencePolicy = "force"  // force-push, the commits that only exist in the target are lost
+)
+
+func ValidateDivergencePolicy(policy DivergencePolicy) bool {
+	return policy == DivergenceAbort || policy == DivergenceBackup || policy == DivergenceForce
+}
+
+// CountLostCommits returns the number of commits of the target branch (origin) that are not reachable from commit
+func (this *GitController) CountLostCommits(commit string, targetBranch string) (int, error) {
+	return this.countLostCommitsRef(commit, "refs/remotes/origin/"+targetBranch, targetBranch)
+}
+
+func (this *GitController) countLostCommitsRef(commit string, trackingRef string, name string) (int, error) {
+	targetSha := this.GetRefHash(trackingRef)
+	if targetSha == "" {
+		return 0, errors.New("The ref " + name + " does not exist in the target")
+	}
+
+	_, lost, err := this.CountDivergence(commit, targetSha)
+	return lost, err
+}
+
+// GuardForceFallback is called after a normal push failed (AutoForceFallback), returns nil if the force-push may be done
+// Only a divergence (the target contains commits that are not in the source) is a reason to force, what happens then is decided by the policy
+// The branch is fetched again first, it can have been created or changed in the target since the last fetch
+func (this *GitController) GuardForceFallback(targetBranch string, remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool, policy DivergencePolicy, backup BackupOptions) error {
+	if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "fetch", "origin", "+refs/heads/"+targetBranch+":refs/remotes/origin/"+targetBranch); err != nil {
+		return err
+	}
+
+	return this.GuardForcePush("HEAD", "refs/remotes/origin/"+targetBranch, targetBranch, remote, cred, credmode, forceNetRCClean, policy, backup)
+}
+
+// GuardForcePush decides by the policy if commit may be force-pushed over the ref of the target, whose fetched state is trackingRef
+// name is the branch (or the full ref) in the target, it is used for the messages and the backup
+func (this *GitController) GuardForcePush(commit string, trackingRef string, name string, remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool, policy DivergencePolicy, backup BackupOptions) error {
+	lost, err := this.countLostCommitsRef(commit, trackingRef, name)
+	if err != nil {
+		return err
+	}
+
+	kind := "branch"
+	if strings.HasPrefix(name, "refs/") {
+		kind = "ref"
+	}
+
+	if lost == 0 {
+		this.Log.Out("Push failed, but the target contains no commits that are missing in the source - not forcing")
+		return errors.New("The push of " + name + " failed for another reason than a divergence (e.g. a protected branch or missing permissions), not forcing")
+	}
+
+	count := strconv.Itoa(lost)
+
+	if policy == DivergenceForce && backup.Mode == BackupModeNone {
+		this.Log.Out("Target contains " + count + " commit(s) that are not in the source - force-pushing, they will be lost (DivergencePolicy = force)")
+		return nil
+	}
+
+	if policy == DivergenceForce || policy == DivergenceBackup {
+		if backup.Mode == BackupModeNone {
+			backup.Mode = BackupModeRef
+		}
+		this.Log.Out("Target contains " + count + " commit(s) that are not in the source - backing them up before force-pushing (DivergencePolicy = " + string(policy) + ")")
+		return this.BackupTargetRef(trackingRef, name, remote, cred, credmode, forceNetRCClean, backup)
+	}
+
+	this.Log.Out("Target contains " + count + " commit(s) that are not in the source - not forcing (DivergencePolicy = abort)")
+	return errors.New("The " + kind + " " + name + " has diverged, a force-push would lose " + count + " commit(s) of the target (see DivergencePolicy)")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ForgeProvider is implemented for every supported value of GGAutoMirrorConfig.Type
+// All repository names are relative to the namespace (GGAutoMirrorConfig.Username) of the provider
+type ForgeProvider interface {
+	ListRepositories() ([]ForgeRepository, error)
+	GetRepository(name string) (*ForgeRepository, error) // returns nil if the repository does not exist
+	CreateRepository(name string, description string, visibility string) error
+	GetDefaultBranch(name string) (string, error)
+	ArchiveRepository(name string) error
+	DeleteRepository(name string) error
+
+	GetCloneURL(name string) string
+}
+
+type ForgeProviderFactory func(amc GGAutoMirrorConfig) ForgeProvider
+
+var ErrForgeNotSupported = errors.New("operation not supported by this forge")
+
+var forgeProviders = make(map[string]ForgeProviderFactory)
+
+// RegisterForgeProvider makes a provider available under the (case-insensitive) type name, call this in an init() func
+func RegisterForgeProvider(forgeType string, factory ForgeProviderFactory) {
+	forgeProviders[strings.ToLower(forgeType)] = factory
+}
+
+func IsForgeTypeRegistered(forgeType string) bool {
+	_, ok := forgeProviders[strings.ToLower(forgeType)]
+	return ok
+}
+
+func RegisteredForgeTypes() []string {
+	result := make([]string, 0, len(forgeProviders))
+	for k := range forgeProviders {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (this GGAutoMirrorConfig) Provider() ForgeProvider {
+	factory, ok := forgeProviders[strings.ToLower(this.Type)]
+	if !ok {
+		EXIT_ERROR("ERROR: Unknown forge type '"+this.Type+"'", EXIT_CONFIG_VALUE_ERROR)
+	}
+	return factory(this)
+}
+
+// defaultBranchFromRepository implements GetDefaultBranch for forges that return the default branch in their repository metadata
+func defaultBranchFromRepository(provider ForgeProvider, name string) (string, error) {
+	repo, err := provider.GetRepository(name)
+	if err != nil {
+		return "", err
+	}
+	if repo == nil {
+		return "", errors.New("Repository '" + name + "' not found")
+	}
+	return repo.DefaultBranch, nil
+}
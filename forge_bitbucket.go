@@ -0,0 +1,315 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type bitbucketLink struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+type bitbucketCloudRepository struct {
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	MainBranch  *struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+	Parent *struct {
+		FullName string `json:"full_name"`
+	} `json:"parent"`
+	IsPrivate bool      `json:"is_private"`
+	UpdatedOn time.Time `json:"updated_on"`
+	Workspace struct {
+		Slug string `json:"slug"`
+	} `json:"workspace"`
+	Links struct {
+		Clone []bitbucketLink `json:"clone"`
+	} `json:"links"`
+}
+
+type bitbucketCloudPage struct {
+	Values []bitbucketCloudRepository `json:"values"`
+	Next   string                     `json:"next"`
+}
+
+type bitbucketServerRepository struct {
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Project     struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Origin *struct {
+		Slug string `json:"slug"`
+	} `json:"origin"`
+	Public   bool `json:"public"`
+	Archived bool `json:"archived"`
+	Links    struct {
+		Clone []bitbucketLink `json:"clone"`
+	} `json:"links"`
+}
+
+type bitbucketServerPage struct {
+	Values        []bitbucketServerRepository `json:"values"`
+	IsLastPage    bool                        `json:"isLastPage"`
+	NextPageStart int                         `json:"nextPageStart"`
+}
+
+type bitbucketCloudCreateRepository struct {
+	Scm         string `json:"scm"`
+	IsPrivate   bool   `json:"is_private"`
+	Description string `json:"description"`
+}
+
+type bitbucketServerCreateRepository struct {
+	Name        string `json:"name"`
+	ScmID       string `json:"scmId"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+}
+
+type bitbucketServerUpdateRepository struct {
+	Archived bool `json:"archived"`
+}
+
+type bitbucketServerBranch struct {
+	DisplayID string `json:"displayId"`
+}
+
+type BitbucketCloudProvider struct {
+	Config GGAutoMirrorConfig
+}
+
+type BitbucketServerProvider struct {
+	Config GGAutoMirrorConfig
+}
+
+func init() {
+	RegisterForgeProvider("bitbucket", func(amc GGAutoMirrorConfig) ForgeProvider { return BitbucketCloudProvider{Config: amc} })
+	RegisterForgeProvider("bitbucketserver", func(amc GGAutoMirrorConfig) ForgeProvider { return BitbucketServerProvider{Config: amc} })
+}
+
+// BitbucketCloudAPIURL returns the 2.0 REST endpoint of bitbucket.org
+func BitbucketCloudAPIURL(amc GGAutoMirrorConfig) string {
+	if amc.APIURL != "" {
+		return amc.APIURL
+	}
+
+	root, err := url.Parse(amc.RootURL)
+	if err == nil && strings.EqualFold(root.Host, "bitbucket.org") {
+		return "https://api.bitbucket.org/2.0"
+	}
+
+	return strings.TrimRight(amc.RootURL, "/") + "/2.0"
+}
+
+// BitbucketServerAPIURL returns the 1.0 REST endpoint of a Bitbucket Server / Data Center instance
+func BitbucketServerAPIURL(amc GGAutoMirrorConfig) string {
+	if amc.APIURL != "" {
+		return amc.APIURL
+	}
+	return strings.TrimRight(amc.RootURL, "/") + "/rest/api/1.0"
+}
+
+// bitbucketCloneURL selects the https link from a links.clone array (and removes the embedded username)
+func bitbucketCloneURL(links []bitbucketLink) string {
+	for _, link := range links {
+		if strings.EqualFold(link.Name, "https") || strings.EqualFold(link.Name, "http") {
+			u, err := url.Parse(link.Href)
+			if err != nil {
+				return link.Href
+			}
+			u.User = nil
+			return u.String()
+		}
+	}
+	return ""
+}
+
+func (this BitbucketCloudProvider) client() ForgeAPIClient {
+	return ForgeAPIClient{BaseURL: BitbucketCloudAPIURL(this.Config), Credentials: this.Config.Credentials}
+}
+
+func (this BitbucketCloudProvider) repoPath(name string) string {
+	return "/repositories/" + url.PathEscape(this.Config.Username) + "/" + url.PathEscape(strings.ToLower(name))
+}
+
+// ListRepositories lists all repositories of the workspace Config.Username
+func (this BitbucketCloudProvider) ListRepositories() ([]ForgeRepository, error) {
+	result := make([]ForgeRepository, 0)
+
+	next := "/repositories/" + url.PathEscape(this.Config.Username) + "?pagelen=100"
+	for next != "" {
+		var page bitbucketCloudPage
+		if _, err := this.client().Request("GET", next, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, repo := range page.Values {
+			result = append(result, repo.toForgeRepository())
+		}
+
+		next = page.Next
+	}
+
+	return result, nil
+}
+
+func (this BitbucketCloudProvider) GetRepository(name string) (*ForgeRepository, error) {
+	var repo bitbucketCloudRepository
+	resp, err := this.client().Request("GET", this.repoPath(name), nil, &repo)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := repo.toForgeRepository()
+	return &result, nil
+}
+
+func (this BitbucketCloudProvider) CreateRepository(name string, description string, visibility string) error {
+	body := bitbucketCloudCreateRepository{
+		Scm:         "git",
+		IsPrivate:   visibility != "public",
+		Description: description,
+	}
+
+	_, err := this.client().Request("POST", this.repoPath(name), body, nil)
+	return err
+}
+
+func (this BitbucketCloudProvider) GetDefaultBranch(name string) (string, error) {
+	return defaultBranchFromRepository(this, name)
+}
+
+func (this BitbucketCloudProvider) ArchiveRepository(name string) error {
+	return ErrForgeNotSupported // bitbucket cloud has no archived repositories
+}
+
+func (this BitbucketCloudProvider) DeleteRepository(name string) error {
+	_, err := this.client().Request("DELETE", this.repoPath(name), nil, nil)
+	return err
+}
+
+func (this BitbucketCloudProvider) GetCloneURL(name string) string {
+	return strings.TrimRight(this.Config.RootURL, "/") + "/" + this.Config.Username + "/" + strings.ToLower(name) + ".git"
+}
+
+func (this bitbucketCloudRepository) toForgeRepository() ForgeRepository {
+	result := ForgeRepository{
+		Name:        this.Slug,
+		Owner:       this.Workspace.Slug,
+		Description: this.Description,
+		CloneURL:    bitbucketCloneURL(this.Links.Clone),
+		Fork:        this.Parent != nil,
+		Private:     this.IsPrivate,
+		PushedAt:    this.UpdatedOn,
+	}
+	if this.MainBranch != nil {
+		result.DefaultBranch = this.MainBranch.Name
+	}
+	return result
+}
+
+func (this BitbucketServerProvider) client() ForgeAPIClient {
+	return ForgeAPIClient{BaseURL: BitbucketServerAPIURL(this.Config), Credentials: this.Config.Credentials}
+}
+
+func (this BitbucketServerProvider) repoPath(name string) string {
+	return "/projects/" + url.PathEscape(this.Config.Username) + "/repos/" + url.PathEscape(strings.ToLower(name))
+}
+
+// ListRepositories lists all repositories of the project with the key Config.Username
+func (this BitbucketServerProvider) ListRepositories() ([]ForgeRepository, error) {
+	result := make([]ForgeRepository, 0)
+
+	start := 0
+	for {
+		var page bitbucketServerPage
+		path := "/projects/" + url.PathEscape(this.Config.Username) + "/repos?limit=100&start=" + strconv.Itoa(start)
+		if _, err := this.client().Request("GET", path, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, repo := range page.Values {
+			result = append(result, repo.toForgeRepository())
+		}
+
+		if page.IsLastPage || len(page.Values) == 0 {
+			break
+		}
+		start = page.NextPageStart
+	}
+
+	return result, nil
+}
+
+func (this BitbucketServerProvider) GetRepository(name string) (*ForgeRepository, error) {
+	var repo bitbucketServerRepository
+	resp, err := this.client().Request("GET", this.repoPath(name), nil, &repo)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := repo.toForgeRepository()
+	return &result, nil
+}
+
+func (this BitbucketServerProvider) CreateRepository(name string, description string, visibility string) error {
+	body := bitbucketServerCreateRepository{
+		Name:        name,
+		ScmID:       "git",
+		Description: description,
+		Public:      visibility == "public",
+	}
+
+	_, err := this.client().Request("POST", "/projects/"+url.PathEscape(this.Config.Username)+"/repos", body, nil)
+	return err
+}
+
+// GetDefaultBranch queries the default branch, which (in contrast to the other forges) is not part of the repository metadata
+func (this BitbucketServerProvider) GetDefaultBranch(name string) (string, error) {
+	var branch bitbucketServerBranch
+	if _, err := this.client().Request("GET", this.repoPath(name)+"/branches/default", nil, &branch); err != nil {
+		return "", err
+	}
+	return branch.DisplayID, nil
+}
+
+// ArchiveRepository needs Bitbucket Server 8.0 or later
+func (this BitbucketServerProvider) ArchiveRepository(name string) error {
+	_, err := this.client().Request("PUT", this.repoPath(name), bitbucketServerUpdateRepository{Archived: true}, nil)
+	return err
+}
+
+func (this BitbucketServerProvider) DeleteRepository(name string) error {
+	_, err := this.client().Request("DELETE", this.repoPath(name), nil, nil)
+	return err
+}
+
+func (this BitbucketServerProvider) GetCloneURL(name string) string {
+	return strings.TrimRight(this.Config.RootURL, "/") + "/scm/" + strings.ToLower(this.Config.Username) + "/" + strings.ToLower(name) + ".git"
+}
+
+func (this bitbucketServerRepository) toForgeRepository() ForgeRepository {
+	return ForgeRepository{
+		Name:        this.Slug,
+		Owner:       this.Project.Key,
+		Description: this.Description,
+		CloneURL:    bitbucketCloneURL(this.Links.Clone),
+		Fork:        this.Origin != nil,
+		Archived:    this.Archived,
+		Private:     !this.Public,
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type giteaRepository struct {
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	CloneURL      string    `json:"clone_url"`
+	DefaultBranch string    `json:"default_branch"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+	Private       bool      `json:"private"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Topics        []string  `json:"topics"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+type giteaUser struct {
+	Login string `json:"login"`
+}
+
+type giteaCreateRepository struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+type giteaEditRepository struct {
+	Archived bool `json:"archived"`
+}
+
+type GiteaProvider struct {
+	Config GGAutoMirrorConfig
+}
+
+func init() {
+	RegisterForgeProvider("gitea", func(amc GGAutoMirrorConfig) ForgeProvider { return GiteaProvider{Config: amc} })
+	RegisterForgeProvider("forgejo", func(amc GGAutoMirrorConfig) ForgeProvider { return GiteaProvider{Config: amc} })
+}
+
+// GiteaAPIURL returns the v1 REST endpoint of a Gitea (or Forgejo) instance
+func GiteaAPIURL(amc GGAutoMirrorConfig) string {
+	if amc.APIURL != "" {
+		return amc.APIURL
+	}
+	return strings.TrimRight(amc.RootURL, "/") + "/api/v1"
+}
+
+func (this GiteaProvider) client() ForgeAPIClient {
+	return ForgeAPIClient{BaseURL: GiteaAPIURL(this.Config), Credentials: this.Config.Credentials}
+}
+
+func (this GiteaProvider) repoPath(name string) string {
+	return "/repos/" + url.PathEscape(this.Config.Username) + "/" + url.PathEscape(name)
+}
+
+// isOrganisation returns true if Config.Username is an organisation (and not a user)
+func (this GiteaProvider) isOrganisation() (bool, error) {
+	resp, err := this.client().Request("GET", "/orgs/"+url.PathEscape(this.Config.Username), nil, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (this GiteaProvider) ListRepositories() ([]ForgeRepository, error) {
+	isOrg, err := this.isOrganisation()
+	if err != nil {
+		return nil, err
+	}
+
+	var path string
+	if isOrg {
+		path = "/orgs/" + url.PathEscape(this.Config.Username) + "/repos?limit=50"
+	} else {
+		path = "/users/" + url.PathEscape(this.Config.Username) + "/repos?limit=50"
+	}
+
+	result := make([]ForgeRepository, 0)
+
+	err = this.client().GetAllPages(path, func(page json.RawMessage) error {
+		var repos []giteaRepository
+		if err := json.Unmarshal(page, &repos); err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			result = append(result, repo.toForgeRepository())
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+func (this GiteaProvider) GetRepository(name string) (*ForgeRepository, error) {
+	var repo giteaRepository
+	resp, err := this.client().Request("GET", this.repoPath(name), nil, &repo)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := repo.toForgeRepository()
+	return &result, nil
+}
+
+// CreateRepository creates a repository for the authenticated user or in the organisation Config.Username
+func (this GiteaProvider) CreateRepository(name string, description string, visibility string) error {
+	isOrg, err := this.isOrganisation()
+	if err != nil {
+		return err
+	}
+
+	body := giteaCreateRepository{
+		Name:        name,
+		Description: description,
+		Private:     visibility != "public", // gitea has no 'internal' repositories
+	}
+
+	if isOrg {
+		_, err = this.client().Request("POST", "/orgs/"+url.PathEscape(this.Config.Username)+"/repos", body, nil)
+		return err
+	}
+
+	// POST /user/repos always creates the repository for the authenticated user (with a token the configured Username can be anything)
+	var user giteaUser
+	if _, err := this.client().Request("GET", "/user", nil, &user); err != nil {
+		return err
+	}
+	if !strings.EqualFold(user.Login, this.Config.Username) {
+		return errors.New("Cannot create repositories for user '" + this.Config.Username + "' while authenticated as '" + user.Login + "'")
+	}
+
+	_, err = this.client().Request("POST", "/user/repos", body, nil)
+	return err
+}
+
+func (this GiteaProvider) GetDefaultBranch(name string) (string, error) {
+	return defaultBranchFromRepository(this, name)
+}
+
+func (this GiteaProvider) ArchiveRepository(name string) error {
+	_, err := this.client().Request("PATCH", this.repoPath(name), giteaEditRepository{Archived: true}, nil)
+	return err
+}
+
+func (this GiteaProvider) DeleteRepository(name string) error {
+	_, err := this.client().Request("DELETE", this.repoPath(name), nil, nil)
+	return err
+}
+
+func (this GiteaProvider) GetCloneURL(name string) string {
+	return strings.TrimRight(this.Config.RootURL, "/") + "/" + this.Config.Username + "/" + name + ".git"
+}
+
+func (this giteaRepository) toForgeRepository() ForgeRepository {
+	return ForgeRepository{
+		Name:          this.Name,
+		Owner:         this.Owner.Login,
+		Description:   this.Description,
+		CloneURL:      this.CloneURL,
+		DefaultBranch: this.DefaultBranch,
+		Fork:          this.Fork,
+		Archived:      this.Archived,
+		Private:       this.Private,
+		PushedAt:      this.UpdatedAt,
+		Topics:        this.Topics,
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGiteaCreateRepository(t *testing.T) {
+	tests := []struct {
+		username string
+		login    string
+		isOrg    bool
+		post     string
+		fails    bool
+	}{
+		{"mirror", "mirror", false, "/user/repos", false},
+		{"Mirror", "mirror", false, "/user/repos", false},
+		{"mirror", "someone", false, "", true},
+		{"backups", "someone", true, "/orgs/backups/repos", false},
+	}
+
+	for _, test := range tests {
+		posted := ""
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == "GET" && r.URL.Path == "/orgs/"+test.username:
+				if !test.isOrg {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(`{}`))
+			case r.Method == "GET" && r.URL.Path == "/user":
+				if r.Header.Get("Authorization") != "Bearer tok12345" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"login":"` + test.login + `"}`))
+			case r.Method == "POST":
+				posted = r.URL.Path
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{}`))
+			default:
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.String())
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		// token credentials have no (or an arbitrary) username
+		provider := GiteaProvider{Config: GGAutoMirrorConfig{RootURL: server.URL, APIURL: server.URL, Username: test.username, Credentials: GGCredentials{Token: "tok12345", TokenStyle: TokenStyleBearer}}}
+		err := provider.CreateRepository("repo", "", "private")
+		server.Close()
+
+		if test.fails != (err != nil) {
+			t.Errorf("[%s as %s] unexpected error %v", test.username, test.login, err)
+		}
+		if posted != test.post {
+			t.Errorf("[%s as %s] expected POST %q, got %q", test.username, test.login, test.post, posted)
+		}
+	}
+}
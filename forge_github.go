@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type githubRepository struct {
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	CloneURL      string    `json:"clone_url"`
+	DefaultBranch string    `json:"default_branch"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+	Private       bool      `json:"private"`
+	PushedAt      time.Time `json:"pushed_at"`
+	Topics        []string  `json:"topics"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+type githubCreateRepository struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+	Visibility  string `json:"visibility,omitempty"`
+}
+
+type githubUpdateRepository struct {
+	Archived bool `json:"archived"`
+}
+
+type GithubProvider struct {
+	Config GGAutoMirrorConfig
+}
+
+func init() {
+	RegisterForgeProvider("github", func(amc GGAutoMirrorConfig) ForgeProvider { return GithubProvider{Config: amc} })
+}
+
+// GithubAPIURL returns the REST endpoint for github.com or the /api/v3 endpoint of a GitHub Enterprise instance
+func GithubAPIURL(amc GGAutoMirrorConfig) string {
+	if amc.APIURL != "" {
+		return amc.APIURL
+	}
+
+	root, err := url.Parse(amc.RootURL)
+	if err == nil && strings.EqualFold(root.Host, "github.com") {
+		return "https://api.github.com"
+	}
+
+	return strings.TrimRight(amc.RootURL, "/") + "/api/v3"
+}
+
+func (this GithubProvider) client() ForgeAPIClient {
+	return ForgeAPIClient{BaseURL: GithubAPIURL(this.Config), Credentials: this.Config.Credentials}
+}
+
+func (this GithubProvider) repoPath(name string) string {
+	return "/repos/" + url.PathEscape(this.Config.Username) + "/" + url.PathEscape(name)
+}
+
+func (this GithubProvider) isOrganisation() (bool, error) {
+	var owner githubUser
+	if _, err := this.client().Request("GET", "/users/"+url.PathEscape(this.Config.Username), nil, &owner); err != nil {
+		return false, err
+	}
+	return strings.EqualFold(owner.Type, "Organization"), nil
+}
+
+func (this GithubProvider) ListRepositories() ([]ForgeRepository, error) {
+	isOrg, err := this.isOrganisation()
+	if err != nil {
+		return nil, err
+	}
+
+	var path string
+	if isOrg {
+		path = "/orgs/" + url.PathEscape(this.Config.Username) + "/repos?type=all&per_page=100"
+	} else if strings.EqualFold(this.Config.Credentials.Username, this.Config.Username) && (!IsEmpty(this.Config.Credentials.Password) || this.Config.Credentials.IsBearer()) {
+		path = "/user/repos?affiliation=owner&visibility=all&per_page=100" // also lists private repositories
+	} else {
+		path = "/users/" + url.PathEscape(this.Config.Username) + "/repos?type=owner&per_page=100"
+	}
+
+	result := make([]ForgeRepository, 0)
+
+	err = this.client().GetAllPages(path, func(page json.RawMessage) error {
+		var repos []githubRepository
+		if err := json.Unmarshal(page, &repos); err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			result = append(result, repo.toForgeRepository())
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+func (this GithubProvider) GetRepository(name string) (*ForgeRepository, error) {
+	var repo githubRepository
+	resp, err := this.client().Request("GET", this.repoPath(name), nil, &repo)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := repo.toForgeRepository()
+	return &result, nil
+}
+
+// CreateRepository creates a repository for the authenticated user or in the organisation Config.Username
+func (this GithubProvider) CreateRepository(name string, description string, visibility string) error {
+	isOrg, err := this.isOrganisation()
+	if err != nil {
+		return err
+	}
+
+	body := githubCreateRepository{
+		Name:        name,
+		Description: description,
+		Private:     visibility != "public",
+	}
+
+	if isOrg {
+		body.Visibility = visibility // 'internal' is only available for organisations
+		_, err = this.client().Request("POST", "/orgs/"+url.PathEscape(this.Config.Username)+"/repos", body, nil)
+		return err
+	}
+
+	// POST /user/repos always creates the repository for the authenticated user (with a token the configured Username can be anything)
+	var user githubUser
+	if _, err := this.client().Request("GET", "/user", nil, &user); err != nil {
+		return err
+	}
+	if !strings.EqualFold(user.Login, this.Config.Username) {
+		return errors.New("Cannot create repositories for user '" + this.Config.Username + "' while authenticated as '" + user.Login + "'")
+	}
+
+	_, err = this.client().Request("POST", "/user/repos", body, nil)
+	return err
+}
+
+func (this GithubProvider) GetDefaultBranch(name string) (string, error) {
+	return defaultBranchFromRepository(this, name)
+}
+
+func (this GithubProvider) ArchiveRepository(name string) error {
+	_, err := this.client().Request("PATCH", this.repoPath(name), githubUpdateRepository{Archived: true}, nil)
+	return err
+}
+
+func (this GithubProvider) DeleteRepository(name string) error {
+	_, err := this.client().Request("DELETE", this.repoPath(name), nil, nil)
+	return err
+}
+
+func (this GithubProvider) GetCloneURL(name string) string {
+	return strings.TrimRight(this.Config.RootURL, "/") + "/" + this.Config.Username + "/" + name + ".git"
+}
+
+func (this githubRepository) toForgeRepository() ForgeRepository {
+	return ForgeRepository{
+		Name:          this.Name,
+		Owner:         this.Owner.Login,
+		Description:   this.Description,
+		CloneURL:      this.CloneURL,
+		DefaultBranch: this.DefaultBranch,
+		Fork:          this.Fork,
+		Archived:      this.Archived,
+		Private:       this.Private,
+		PushedAt:      this.PushedAt,
+		Topics:        this.Topics,
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newGithubTestServer(t *testing.T, userType string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/mikescher":
+			_, _ = w.Write([]byte(`{"login":"mikescher","type":"` + userType + `"}`))
+		case "/users/mikescher/repos", "/orgs/mikescher/repos":
+			if r.URL.Query().Get("page") == "2" {
+				_, _ = w.Write([]byte(`[{"name":"c","default_branch":"main","archived":true,"owner":{"login":"mikescher"}}]`))
+				return
+			}
+			w.Header().Set("Link", `<`+server.URL+r.URL.Path+`?page=2>; rel="next"`)
+			_, _ = w.Write([]byte(`[{"name":"a","default_branch":"master","owner":{"login":"mikescher"}},{"name":"b","fork":true,"topics":["mirror"],"owner":{"login":"mikescher"}}]`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.String())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func TestGithubListRepositories(t *testing.T) {
+	for _, userType := range []string{"User", "Organization"} {
+		server := newGithubTestServer(t, userType)
+
+		provider := GithubProvider{Config: GGAutoMirrorConfig{RootURL: server.URL, APIURL: server.URL, Username: "mikescher"}}
+
+		repos, err := provider.ListRepositories()
+		server.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(repos) != 3 {
+			t.Fatalf("[%s] expected 3 repositories, got %d", userType, len(repos))
+		}
+		if repos[0].Name != "a" || repos[0].DefaultBranch != "master" || repos[0].Owner != "mikescher" {
+			t.Errorf("[%s] unexpected first repository %+v", userType, repos[0])
+		}
+		if !repos[1].Fork || len(repos[1].Topics) != 1 || repos[1].Topics[0] != "mirror" {
+			t.Errorf("[%s] unexpected second repository %+v", userType, repos[1])
+		}
+		if repos[2].Name != "c" || !repos[2].Archived {
+			t.Errorf("[%s] unexpected third repository %+v", userType, repos[2])
+		}
+	}
+}
+
+func TestGithubAPIURL(t *testing.T) {
+	if url := GithubAPIURL(GGAutoMirrorConfig{RootURL: "https://github.com"}); url != "https://api.github.com" {
+		t.Errorf("expected https://api.github.com, got %s", url)
+	}
+	if url := GithubAPIURL(GGAutoMirrorConfig{RootURL: "https://git.example.com/"}); url != "https://git.example.com/api/v3" {
+		t.Errorf("expected https://git.example.com/api/v3, got %s", url)
+	}
+}
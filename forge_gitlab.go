@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type gitlabNamespace struct {
+	ID       int    `json:"id"`
+	FullPath string `json:"full_path"`
+	Kind     string `json:"kind"`
+}
+
+type gitlabProject struct {
+	ID                int    `json:"id"`
+	Path              string `json:"path"`
+	Description       string `json:"description"`
+	HTTPURLToRepo     string `json:"http_url_to_repo"`
+	DefaultBranch     string `json:"default_branch"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Namespace         struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+	ForkedFromProject *struct {
+		ID int `json:"id"`
+	} `json:"forked_from_project"`
+	Archived       bool      `json:"archived"`
+	Visibility     string    `json:"visibility"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+	Topics         []string  `json:"topics"`
+	TagList        []string  `json:"tag_list"` // GitLab < 14.0
+}
+
+type gitlabUser struct {
+	Username string `json:"username"`
+}
+
+type gitlabCreateProject struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	NamespaceID int    `json:"namespace_id"`
+	Visibility  string `json:"visibility"`
+	Description string `json:"description"`
+}
+
+type GitlabProvider struct {
+	Config GGAutoMirrorConfig
+}
+
+func init() {
+	RegisterForgeProvider("gitlab", func(amc GGAutoMirrorConfig) ForgeProvider { return GitlabProvider{Config: amc} })
+}
+
+// GitlabAPIURL returns the v4 REST endpoint of a GitLab instance
+func GitlabAPIURL(amc GGAutoMirrorConfig) string {
+	if amc.APIURL != "" {
+		return amc.APIURL
+	}
+	return strings.TrimRight(amc.RootURL, "/") + "/api/v4"
+}
+
+func (this GitlabProvider) client() ForgeAPIClient {
+	return ForgeAPIClient{
+		BaseURL:     GitlabAPIURL(this.Config),
+		Credentials: this.Config.Credentials,
+		Authorize: func(req *http.Request, cred GGCredentials) {
+			if cred.IsBearer() {
+				cred.AuthorizeRequest(req)
+			} else if !IsEmpty(cred.Password) {
+				req.Header.Set("PRIVATE-TOKEN", cred.Password)
+			}
+		},
+	}
+}
+
+func (this GitlabProvider) projectPath(name string) string {
+	return "/projects/" + url.PathEscape(this.Config.Username+"/"+name)
+}
+
+func (this GitlabProvider) namespace() (gitlabNamespace, error) {
+	var namespace gitlabNamespace
+	_, err := this.client().Request("GET", "/namespaces/"+url.PathEscape(this.Config.Username), nil, &namespace)
+	return namespace, err
+}
+
+// isAuthenticatedUser returns true if the credentials belong to the user Config.Username
+func (this GitlabProvider) isAuthenticatedUser() (bool, error) {
+	cred := this.Config.Credentials
+	if !cred.IsBearer() && IsEmpty(cred.Password) {
+		return false, nil // anonymous
+	}
+
+	var user gitlabUser
+	if _, err := this.client().Request("GET", "/user", nil, &user); err != nil {
+		return false, err
+	}
+	return strings.EqualFold(user.Username, this.Config.Username), nil
+}
+
+func (this GitlabProvider) ListRepositories() ([]ForgeRepository, error) {
+	namespace, err := this.namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	ownNamespace := false
+
+	var path string
+	if namespace.Kind == "group" {
+		path = "/groups/" + strconv.Itoa(namespace.ID) + "/projects?per_page=100"
+	} else if ownNamespace, err = this.isAuthenticatedUser(); err != nil {
+		return nil, err
+	} else if ownNamespace {
+		path = "/projects?owned=true&per_page=100" // /users/:user/projects only lists the public projects
+	} else {
+		path = "/users/" + url.PathEscape(this.Config.Username) + "/projects?per_page=100"
+	}
+
+	result := make([]ForgeRepository, 0)
+
+	err = this.client().GetAllPages(path, func(page json.RawMessage) error {
+		var projects []gitlabProject
+		if err := json.Unmarshal(page, &projects); err != nil {
+			return err
+		}
+		for _, project := range projects {
+			if ownNamespace && !strings.EqualFold(project.Namespace.FullPath, this.Config.Username) {
+				continue // owned projects in groups
+			}
+			result = append(result, project.toForgeRepository())
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+func (this GitlabProvider) GetRepository(name string) (*ForgeRepository, error) {
+	var project gitlabProject
+	resp, err := this.client().Request("GET", this.projectPath(name), nil, &project)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := project.toForgeRepository()
+	return &result, nil
+}
+
+// CreateRepository creates a project in the (user or group) namespace Config.Username
+func (this GitlabProvider) CreateRepository(name string, description string, visibility string) error {
+	namespace, err := this.namespace()
+	if err != nil {
+		return err
+	}
+
+	body := gitlabCreateProject{
+		Name:        name,
+		Path:        name,
+		NamespaceID: namespace.ID,
+		Visibility:  visibility,
+		Description: description,
+	}
+
+	_, err = this.client().Request("POST", "/projects", body, nil)
+	return err
+}
+
+func (this GitlabProvider) GetDefaultBranch(name string) (string, error) {
+	return defaultBranchFromRepository(this, name)
+}
+
+func (this GitlabProvider) ArchiveRepository(name string) error {
+	_, err := this.client().Request("POST", this.projectPath(name)+"/archive", nil, nil)
+	return err
+}
+
+func (this GitlabProvider) DeleteRepository(name string) error {
+	_, err := this.client().Request("DELETE", this.projectPath(name), nil, nil)
+	return err
+}
+
+func (this GitlabProvider) GetCloneURL(name string) string {
+	return strings.TrimRight(this.Config.RootURL, "/") + "/" + this.Config.Username + "/" + name + ".git"
+}
+
+func (this gitlabProject) toForgeRepository() ForgeRepository {
+	return ForgeRepository{
+		Name:          this.Path,
+		Owner:         this.Namespace.FullPath,
+		Description:   this.Description,
+		CloneURL:      this.HTTPURLToRepo,
+		DefaultBranch: this.DefaultBranch,
+		Fork:          this.ForkedFromProject != nil,
+		Archived:      this.Archived,
+		Private:       this.Visibility != "public",
+		PushedAt:      this.LastActivityAt,
+		Topics:        append(this.Topics, this.TagList...),
+	}
+}
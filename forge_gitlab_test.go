@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitlabListRepositories(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		cred     GGCredentials
+		login    string
+		path     string
+		expected []string
+	}{
+		{"own user with token", "user", GGCredentials{Token: "tok12345", TokenStyle: TokenStyleBearer}, "mirror", "/projects", []string{"public", "private"}},
+		{"own user with password", "user", GGCredentials{Username: "mirror", Password: "glpat-12345"}, "Mirror", "/projects", []string{"public", "private"}},
+		{"other user", "user", GGCredentials{Token: "tok12345", TokenStyle: TokenStyleBearer}, "someone", "/users/mirror/projects", []string{"public"}},
+		{"anonymous", "user", GGCredentials{}, "", "/users/mirror/projects", []string{"public"}},
+		{"group", "group", GGCredentials{Token: "tok12345", TokenStyle: TokenStyleBearer}, "someone", "/groups/42/projects", []string{"public"}},
+	}
+
+	for _, test := range tests {
+		listed := ""
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/namespaces/mirror":
+				_, _ = w.Write([]byte(`{"id":42,"full_path":"mirror","kind":"` + test.kind + `"}`))
+			case "/user":
+				if test.login == "" {
+					t.Errorf("[%s] anonymous requests must not query /user", test.name)
+				}
+				_, _ = w.Write([]byte(`{"username":"` + test.login + `"}`))
+			case "/projects":
+				listed = r.URL.Path
+				if r.URL.Query().Get("owned") != "true" {
+					t.Errorf("[%s] expected owned=true, got %s", test.name, r.URL.RawQuery)
+				}
+				_, _ = w.Write([]byte(`[{"path":"public","visibility":"public","namespace":{"full_path":"mirror"}},{"path":"private","visibility":"private","namespace":{"full_path":"mirror"}},{"path":"grouped","visibility":"private","namespace":{"full_path":"some-group"}}]`))
+			case "/users/mirror/projects", "/groups/42/projects":
+				listed = r.URL.Path
+				_, _ = w.Write([]byte(`[{"path":"public","visibility":"public","namespace":{"full_path":"mirror"}}]`))
+			default:
+				t.Errorf("[%s] unexpected request %s %s", test.name, r.Method, r.URL.String())
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		provider := GitlabProvider{Config: GGAutoMirrorConfig{RootURL: server.URL, APIURL: server.URL, Username: "mirror", Credentials: test.cred}}
+		repos, err := provider.ListRepositories()
+		server.Close()
+
+		if err != nil {
+			t.Errorf("[%s] %v", test.name, err)
+			continue
+		}
+		if listed != test.path {
+			t.Errorf("[%s] expected the projects of %s, got %s", test.name, test.path, listed)
+		}
+
+		names := make([]string, 0, len(repos))
+		for _, repo := range repos {
+			names = append(names, repo.Name)
+		}
+		if len(names) != len(test.expected) {
+			t.Errorf("[%s] expected %v, got %v", test.name, test.expected, names)
+			continue
+		}
+		for i := range names {
+			if names[i] != test.expected[i] {
+				t.Errorf("[%s] expected %v, got %v", test.name, test.expected, names)
+			}
+		}
+	}
+}
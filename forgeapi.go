@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ForgeHTTPClient is used for all forge API calls, can be replaced (e.g. by an httptest client)
+var ForgeHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// ForgeRateLimitMaxWait is the longest we are willing to sleep for a rate-limit reset
+var ForgeRateLimitMaxWait = 15 * time.Minute
+
+const forgeMaxRetries = 3
+
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="next"`)
+
+type ForgeRepository struct {
+	Name          string
+	Owner         string
+	Description   string
+	CloneURL      string
+	DefaultBranch string
+
+	Fork     bool
+	Archived bool
+	Private  bool
+	PushedAt time.Time // zero if the forge does not report it
+	Topics   []string
+}
+
+type ForgeAPIClient struct {
+	BaseURL     string
+	Credentials GGCredentials
+
+	Authorize func(req *http.Request, cred GGCredentials) // if not set basic-auth (or the bearer token) is used
+}
+
+func (this ForgeAPIClient) httpClient() *http.Client {
+	if !this.Credentials.NoSSLVerify {
+		return ForgeHTTPClient
+	}
+
+	client := *ForgeHTTPClient
+	client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	return &client
+}
+
+func (this ForgeAPIClient) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return strings.TrimRight(this.BaseURL, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// Request executes a single API call, JSON-encodes body (if not nil) and JSON-decodes the response into result (if not nil)
+// Non-2xx responses are returned as an error, but the response is still returned so callers can inspect the status code
+func (this ForgeAPIClient) Request(method string, path string, body interface{}, result interface{}) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	uri := this.url(path)
+
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if payload != nil {
+			reader = bytes.NewReader(payload)
+		}
+
+		req, err := http.NewRequest(method, uri, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", PROGNAME+"/"+PROGVERSION)
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		if this.Authorize != nil {
+			this.Authorize(req, this.Credentials)
+		} else {
+			this.Credentials.AuthorizeRequest(req)
+		}
+
+		resp, err := this.httpClient().Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return resp, err
+		}
+
+		if wait, limited := rateLimitWait(resp); limited && attempt < forgeMaxRetries {
+			if wait > ForgeRateLimitMaxWait {
+				return resp, errors.New("API rate limit exceeded for " + uri + " (reset in " + wait.String() + ")")
+			}
+			LOG_OUT("API rate limit exceeded, waiting " + wait.String() + " before retrying")
+			time.Sleep(wait)
+			continue
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return resp, errors.New(method + " " + uri + " returned " + resp.Status + "\n" + strings.TrimSpace(string(respBody)))
+		}
+
+		if result != nil && len(respBody) > 0 {
+			if err := json.Unmarshal(respBody, result); err != nil {
+				return resp, errors.New("Cannot parse response of " + uri + ": " + err.Error())
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// GetAllPages follows the RFC 5988 'Link: <...>; rel="next"' header and calls onPage with the raw body of every page
+func (this ForgeAPIClient) GetAllPages(path string, onPage func(page json.RawMessage) error) error {
+	uri := this.url(path)
+
+	for uri != "" {
+		var page json.RawMessage
+		resp, err := this.Request("GET", uri, nil, &page)
+		if err != nil {
+			return err
+		}
+
+		if err := onPage(page); err != nil {
+			return err
+		}
+
+		uri = NextPageLink(resp)
+
+		if uri != "" {
+			if wait, exhausted := rateLimitExhausted(resp); exhausted {
+				if wait > ForgeRateLimitMaxWait {
+					return errors.New("API rate limit exhausted (reset in " + wait.String() + ")")
+				}
+				LOG_OUT("API rate limit exhausted, waiting " + wait.String() + " before requesting next page")
+				time.Sleep(wait)
+			}
+		}
+	}
+
+	return nil
+}
+
+func NextPageLink(resp *http.Response) string {
+	for _, link := range resp.Header.Values("Link") {
+		if m := linkNextRegex.FindStringSubmatch(link); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// rateLimitWait returns true if the request was rejected because of a rate limit (and how long we should wait)
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if retry := resp.Header.Get("Retry-After"); retry != "" {
+		if sec, err := strconv.Atoi(retry); err == nil {
+			return time.Duration(sec) * time.Second, true
+		}
+	}
+
+	return rateLimitExhausted(resp)
+}
+
+// rateLimitExhausted returns true if the X-RateLimit-Remaining header says that no more requests are allowed
+func rateLimitExhausted(resp *http.Response) (time.Duration, bool) {
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	if remaining != "0" {
+		return 0, false
+	}
+
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Minute, true
+	}
+
+	wait := time.Until(time.Unix(reset, 0)) + time.Second
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait, true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGetAllPagesFollowsLinkHeader(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			w.Header().Add("Link", `<`+server.URL+`/items?page=`+strconv.Itoa(page+1)+`>; rel="next", <`+server.URL+`/items?page=3>; rel="last"`)
+		}
+		_, _ = w.Write([]byte(`[` + strconv.Itoa(page) + `]`))
+	}))
+	defer server.Close()
+
+	client := ForgeAPIClient{BaseURL: server.URL}
+
+	pages := make([]int, 0)
+	err := client.GetAllPages("/items", func(page json.RawMessage) error {
+		var values []int
+		if err := json.Unmarshal(page, &values); err != nil {
+			return err
+		}
+		pages = append(pages, values...)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 || pages[0] != 1 || pages[1] != 2 || pages[2] != 3 {
+		t.Errorf("expected pages [1 2 3], got %v", pages)
+	}
+}
+
+func TestRequestRetriesAfterRateLimit(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"login":"mirror"}`))
+	}))
+	defer server.Close()
+
+	var user githubUser
+	if _, err := (ForgeAPIClient{BaseURL: server.URL}).Request("GET", "/user", nil, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 requests, got %d", calls)
+	}
+	if user.Login != "mirror" {
+		t.Errorf("expected login 'mirror', got '%s'", user.Login)
+	}
+}
+
+func TestRequestFailsIfRateLimitResetIsTooFar(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if _, err := (ForgeAPIClient{BaseURL: server.URL}).Request("GET", "/user", nil, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if calls != 1 {
+		t.Errorf("expected 1 request, got %d", calls)
+	}
+}
+
+func TestRequestGivesUpAfterMaxRetries(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	resp, err := (ForgeAPIClient{BaseURL: server.URL}).Request("GET", "/user", nil, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the 429 response to be returned")
+	}
+	if calls != forgeMaxRetries+1 {
+		t.Errorf("expected %d requests, got %d", forgeMaxRetries+1, calls)
+	}
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -17,7 +19,22 @@ type GGMConfig struct {
 	AutoForceFallback   bool
 	AlwaysCleanNetRC    bool
 	FastUpdateCheck     bool
+	FailFast            bool // abort the cron run at the first git error (instead of continuing with the next remote)
+	PruneDryRun         bool // only print the refs that would be deleted by PruneTarget
+	SubmoduleReport     bool // print the submodule urls that are not covered by any remote after cron
 	CredentialMode      CredMode
+	LockMode            LockMode // what to do if another instance holds the lock [wait, skip, fail] (default == fail)
+	Parallelism         int      // number of remotes that are updated in parallel by cron (default == 1)
+
+	DivergencePolicy DivergencePolicy // AutoForceFallback: what to do if the target contains commits that are not in the source [abort, backup, force] (default == abort)
+
+	ForceBackup             BackupMode    // back up the old tip before a force-push overwrites commits of the target [none, ref, bundle] (default == none)
+	BackupNamespace         string        // ForceBackup == ref: namespace of the backup refs in the target (default == refs/gogitmirror/backup)
+	BackupFolder            string        // ForceBackup == bundle: folder of the bundles (default == TemporaryPath/gogitmirror/backups)
+	BackupRetention         string        // ForceBackup == bundle: delete bundles older than this (e.g. '30d'), if not set they are kept forever
+	BackupRetentionDuration time.Duration // set by code
+
+	LegacySecrets int // number of values with the legacy 'aes:' encryption, set by code
 
 	Credentials []GGCredentials
 
@@ -34,18 +51,56 @@ const (
 	CredModeCFile  CredMode = "CREDFILE"
 )
 
+type MirrorMode string
+
+const (
+	MirrorModeBranches MirrorMode = "branches" // checkout and push every branch (default)
+	MirrorModeMirror   MirrorMode = "mirror"   // bare repository, all refs are synced with a single fetch and push
+)
+
+type SyncDirection string
+
+const (
+	DirectionOneWay SyncDirection = "oneway" // push the source to the target (default)
+	DirectionBoth   SyncDirection = "both"   // fast-forward whichever side is behind, diverged branches are reported and never forced
+)
+
 type GGMirror struct {
 	ID string
 
 	Source string
 	Target string
 
+	Targets []GGMirrorTarget // push the source to multiple targets (instead of Target), it is only fetched once
+
 	Force bool
 
-	PrimaryBranch string // Used for AutoBranchDiscovery (default == master)
+	Direction SyncDirection // [oneway, both] (default == oneway), both needs write access to the source
+
+	DivergencePolicy DivergencePolicy // overrides GGMConfig.DivergencePolicy for this remote
+
+	Mode           MirrorMode // [branches, mirror] (default == branches)
+	MirrorRefSpecs []string   // Mode == mirror: push only these refspecs (e.g. 'refs/heads/*:refs/heads/*') instead of all refs (except pull/merge request refs)
+
+	RefMap []GGRefMapping // rename branches in the target (first match wins, unmatched branches keep their name), ignored if Mode == mirror
+
+	LFS bool // also mirror the Git LFS objects (needs git-lfs)
+
+	Submodules      bool   // discover the submodules in the mirrored branches
+	SubmoduleTarget string // if set, submodules without a remote are mirrored to this url ('{name}' is replaced by the repository name)
+
+	Tags TagMode // [none, follow, all, all+prune, all+force] (default == follow), ignored if Mode == mirror
+
+	PruneTarget       bool // delete branches and tags from the target that no longer exist in the source (Mode == mirror always prunes)
+	PruneOnlyMirrored bool // PruneTarget: only delete refs that were previously pushed by gogitmirror
+	PruneLimit        *int // PruneTarget and Tags = all+prune/all+force: delete nothing if more than this many refs would be deleted in one run (default == 10, 0 == never delete, -1 == unlimited)
+
+	PrimaryBranch string // Used for AutoBranchDiscovery and pushed first (default == master), the RefMap applies to it like to every other branch
 
 	Branches            []string // If not set AutoBranchDiscovery becomes true
 	AutoBranchDiscovery bool     // normally not set via TOML, but auto assigned based on host
+	IncludeBranches     []string // If set only discovered branches matching one of these patterns are mirrored (glob or 'regex:...', '!pattern' excludes)
+	ExcludeBranches     []string // Discovered branches matching one of these patterns are never mirrored (e.g. 'dependabot/*')
 
 	SourceCredentials GGCredentials // normally not set via TOML, but auto assigned based on host
 	TargetCredentials GGCredentials // normally not set via TOML, but auto assigned based on host
@@ -54,22 +109,49 @@ type GGMirror struct {
 	TargetCredentialsID string // if set, use these credentials (by-id)
 
 	TempBaseFolder string // normally not set via TOML, but auto assigned from root config
+
+	Log *LogBuffer // set by code, nil logs directly to stdout
+}
+
+// GGRefMapping maps the source branch to another branch name in the target, e.g. '*' -> 'mirror/*' or 'regex:^release-(.*)$' -> 'release/$1'
+type GGRefMapping struct {
+	Pattern     string // glob (a '*' also matches '/') or 'regex:...'
+	Replacement string // '*' is replaced by the text matched by the corresponding '*' of a glob, '$1' by the capture group of a regex
 }
 
 type GGAutoMirror struct {
 	Source GGAutoMirrorConfig
 	Target GGAutoMirrorConfig
 
-	OnlyMasterBranch bool
+	OnlyDefaultBranch bool     // only mirror the default branch reported by the source forge
+	OnlyMasterBranch  bool     // deprecated, same as OnlyDefaultBranch
+	Branches          []string // only mirror these branches
+	BranchPatterns    []string // only mirror the branches matching these patterns (e.g. 'release/*')
+
+	CreateTargets    bool   // create missing repositories on the target forge before the first push
+	TargetVisibility string // visibility of created repositories [private, internal, public] (default == private)
+
+	Include      []string // if set only repositories with a matching name are mirrored (glob or 'regex:...')
+	Exclude      []string // repositories with a matching name are never mirrored (glob or 'regex:...')
+	Topics       []string // if set only repositories with at least one of these topics are mirrored
+	SkipForks    bool
+	SkipArchived bool
+	SkipPrivate  bool
+	SkipPublic   bool
+
+	MinPushAge         string        // only mirror repositories whose last push is at least this old (e.g. '12h' or '7d')
+	MinPushAgeDuration time.Duration // set by code
 }
 
 type GGAutoMirrorConfig struct {
-	Type string // [Github, Gitea, Gitlab, Bitbucket]
+	Type string // [Github, Gitea, Forgejo, Gitlab, Bitbucket, BitbucketServer], see RegisterForgeProvider
 
 	RootURL  string // e.g. https://gilab.com
-	Username string
+	APIURL   string // if not set derived from RootURL (e.g. https://api.github.com)
+	Username string // user or organisation that owns the repositories (workspace for Bitbucket, project-key for BitbucketServer)
 
-	Credentials GGCredentials // normally not set via TOML, but auto assigned based on host
+	Credentials   GGCredentials // normally not set via TOML, but auto assigned based on host
+	CredentialsID string        // if set, use these credentials (by-id)
 }
 
 type GGCredentials struct {
@@ -78,7 +160,15 @@ type GGCredentials struct {
 	Host string // Host is empty string for anonymous login
 
 	Username string
-	Password string
+	Password string // plain, encrypted ('aes2:...') or a reference ('env:NAME', 'file:/path' or 'cmd:command')
+
+	Token      string     // access token instead of Username/Password (encrypted or a reference like Password)
+	TokenStyle TokenStyle // how the token is sent [gitlab, github, bitbucket, bearer] (default is derived from Host)
+
+	SSHKeyPath            string // private key for ssh:// and scp-like urls (git@host:path)
+	SSHKeyPassphrase      string // passphrase of SSHKeyPath (encrypted or a reference like Password)
+	KnownHostsPath        string // if set used instead of ~/.ssh/known_hosts
+	StrictHostKeyChecking string // [yes, accept-new, no] (default == ssh config)
 
 	NoSSLVerify bool   // default = false
 	UniqID      string // set by code
@@ -88,7 +178,7 @@ func (this GGCredentials) Str() string {
 	if this.ID != "" {
 		return "{{" + this.ID + "}}"
 	} else {
-		return "[" + this.Username + "@" + this.Host + "]"
+		return RedactSecrets("[" + this.Username + "@" + this.Host + "]")
 	}
 }
 
@@ -102,96 +192,414 @@ func (this *GGMConfig) LoadFromFile(path string) {
 		this.CredentialMode = CredModeCFile
 	}
 
-	for i := 0; i < len(this.Credentials); i++ {
-		if this.Credentials[i].Host == "" {
-			EXIT_ERROR("ERROR: Credentials must have the property 'Host' set", EXIT_CONFIG_READ_ERROR)
-		}
+	if this.LockMode == "" {
+		this.LockMode = LockModeFail
+	}
+
+	if this.LockMode != LockModeWait && this.LockMode != LockModeSkip && this.LockMode != LockModeFail {
+		EXIT_ERROR("ERROR: Invalid LockMode '"+string(this.LockMode)+"' (must be one of [wait, skip, fail])", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	if this.DivergencePolicy == "" {
+		this.DivergencePolicy = DivergenceAbort
+	}
+
+	if !ValidateDivergencePolicy(this.DivergencePolicy) {
+		EXIT_ERROR("ERROR: Invalid DivergencePolicy '"+string(this.DivergencePolicy)+"' (must be one of [abort, backup, force])", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	if this.ForceBackup == "" {
+		this.ForceBackup = BackupModeNone
+	}
+
+	if this.ForceBackup != BackupModeNone && this.ForceBackup != BackupModeRef && this.ForceBackup != BackupModeBundle {
+		EXIT_ERROR("ERROR: Invalid ForceBackup '"+string(this.ForceBackup)+"' (must be one of [none, ref, bundle])", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	if this.BackupNamespace == "" {
+		this.BackupNamespace = "refs/gogitmirror/backup"
+	}
 
-		if len(this.Credentials[i].Password) > 5 && this.Credentials[i].Password[:4] == "aes:" {
-			this.Credentials[i].Password = Decrypt(this.Credentials[i].Password[4:])
-		} else if this.Credentials[i].Password != "" {
-			LOG_OUT("WARNING: password for host " + this.Credentials[i].Host + " is unencrypted")
+	this.BackupNamespace = strings.TrimRight(this.BackupNamespace, "/")
+
+	if !strings.HasPrefix(this.BackupNamespace, "refs/") {
+		EXIT_ERROR("ERROR: Invalid BackupNamespace '"+this.BackupNamespace+"' (must start with 'refs/')", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	if this.BackupFolder == "" {
+		this.BackupFolder = filepath.Join(this.TemporaryPath, TEMPFOLDERNAME, "backups")
+	}
+
+	if this.BackupRetention != "" {
+		age, err := ParseAge(this.BackupRetention)
+		if err != nil {
+			EXIT_ERROR("ERROR: Invalid BackupRetention '"+this.BackupRetention+"'\n\n"+err.Error(), EXIT_CONFIG_VALUE_ERROR)
 		}
+		this.BackupRetentionDuration = age
+	}
 
-		this.Credentials[i].UniqID = "GGMCRED_" + strconv.Itoa(i)
+	if this.Parallelism <= 0 {
+		this.Parallelism = 1
+	}
+
+	for i := 0; i < len(this.Credentials); i++ {
+		this.InitCredential(i)
 	}
 
 	for i := 0; i < len(this.Remote); i++ {
-		if this.Remote[i].Source == "" {
-			EXIT_ERROR("ERROR: Every remote must have the property 'Source' set", EXIT_CONFIG_READ_ERROR)
+		this.InitRemote(&this.Remote[i])
+	}
+
+	for i := 0; i < len(this.AutoMirror); i++ {
+		this.InitAutoMirrorConfig(&this.AutoMirror[i].Source)
+		this.InitAutoMirrorConfig(&this.AutoMirror[i].Target)
+
+		if this.AutoMirror[i].TargetVisibility == "" {
+			this.AutoMirror[i].TargetVisibility = "private"
 		}
 
-		if this.Remote[i].Target == "" {
-			EXIT_ERROR("ERROR: Every remote must have the property 'Target' set", EXIT_CONFIG_READ_ERROR)
+		if !Contains([]string{"private", "internal", "public"}, this.AutoMirror[i].TargetVisibility) {
+			EXIT_ERROR("ERROR: Invalid TargetVisibility '"+this.AutoMirror[i].TargetVisibility+"' (must be one of [private, internal, public])", EXIT_CONFIG_VALUE_ERROR)
 		}
 
-		if this.Remote[i].TempBaseFolder == "" {
-			this.Remote[i].TempBaseFolder = this.TemporaryPath
+		if this.AutoMirror[i].OnlyMasterBranch {
+			this.AutoMirror[i].OnlyDefaultBranch = true
 		}
 
-		if this.Remote[i].Branches == nil {
-			this.Remote[i].Branches = []string{} // Default value
-			this.Remote[i].AutoBranchDiscovery = true
-		} else {
-			this.Remote[i].AutoBranchDiscovery = false
+		branchRules := 0
+		if this.AutoMirror[i].OnlyDefaultBranch {
+			branchRules++
+		}
+		if this.AutoMirror[i].Branches != nil {
+			branchRules++
+		}
+		if this.AutoMirror[i].BranchPatterns != nil {
+			branchRules++
+		}
+		if branchRules > 1 {
+			EXIT_ERROR("ERROR: Only one of OnlyDefaultBranch, Branches and BranchPatterns can be set in an AutoMirror", EXIT_CONFIG_VALUE_ERROR)
 		}
 
-		if this.Remote[i].PrimaryBranch == "" {
-			this.Remote[i].PrimaryBranch = "master"
+		for _, pattern := range this.AutoMirror[i].BranchPatterns {
+			if err := ValidatePattern(pattern); err != nil {
+				EXIT_ERROR("ERROR: Invalid AutoMirror branch pattern '"+pattern+"'\n\n"+err.Error(), EXIT_CONFIG_VALUE_ERROR)
+			}
 		}
 
-		urlSource, err := url.Parse(this.Remote[i].Source)
+		for _, pattern := range append(this.AutoMirror[i].Include, this.AutoMirror[i].Exclude...) {
+			if err := ValidatePattern(pattern); err != nil {
+				EXIT_ERROR("ERROR: Invalid AutoMirror pattern '"+pattern+"'\n\n"+err.Error(), EXIT_CONFIG_VALUE_ERROR)
+			}
+		}
+
+		if this.AutoMirror[i].MinPushAge != "" {
+			age, err := ParseAge(this.AutoMirror[i].MinPushAge)
+			if err != nil {
+				EXIT_ERROR("ERROR: Invalid MinPushAge '"+this.AutoMirror[i].MinPushAge+"'\n\n"+err.Error(), EXIT_CONFIG_VALUE_ERROR)
+			}
+			this.AutoMirror[i].MinPushAgeDuration = age
+		}
+	}
+}
+
+// LoadCredentialFromFile reads only the credentials with the UniqID from the config (used by the credential helper and askpass)
+// The other credentials are not resolved, so their secret references (e.g. 'cmd:') are never executed
+func (this *GGMConfig) LoadCredentialFromFile(path string, uniqid string) (GGCredentials, bool) {
+
+	if _, err := toml.DecodeFile(path, &this); err != nil {
+		EXIT_ERROR("ERROR: Cannot load config from "+path+"\n\n"+err.Error(), EXIT_CONFIG_READ_ERROR)
+	}
+
+	for i := 0; i < len(this.Credentials); i++ {
+		if "GGMCRED_"+strconv.Itoa(i) == uniqid {
+			this.InitCredential(i)
+			return this.Credentials[i], true
+		}
+	}
+
+	return GGCredentials{}, false
+}
+
+// InitCredential resolves and validates the secrets of the credentials with the index i
+func (this *GGMConfig) InitCredential(i int) {
+	cred := &this.Credentials[i]
+
+	if cred.Host == "" {
+		EXIT_ERROR("ERROR: Credentials must have the property 'Host' set", EXIT_CONFIG_READ_ERROR)
+	}
+
+	cred.Password = this.ResolveCredentialValue(cred.Password, "password", cred.Host)
+	cred.Token = this.ResolveCredentialValue(cred.Token, "token", cred.Host)
+	cred.SSHKeyPassphrase = this.ResolveCredentialValue(cred.SSHKeyPassphrase, "ssh key passphrase", cred.Host)
+
+	if err := cred.ApplyToken(); err != nil {
+		EXIT_ERROR("ERROR: Invalid token for host "+cred.Host+"\n\n"+err.Error(), EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	if cred.SSHKeyPath != "" {
+		cred.SSHKeyPath = ExpandPath(cred.SSHKeyPath)
+		if !PathExists(cred.SSHKeyPath) {
+			EXIT_ERROR("ERROR: The SSHKeyPath '"+cred.SSHKeyPath+"' for host "+cred.Host+" does not exist", EXIT_CONFIG_VALUE_ERROR)
+		}
+	}
+
+	if cred.KnownHostsPath != "" {
+		cred.KnownHostsPath = ExpandPath(cred.KnownHostsPath)
+	}
+
+	cred.StrictHostKeyChecking = strings.ToLower(cred.StrictHostKeyChecking)
+
+	if !Contains([]string{"", "yes", "accept-new", "no"}, cred.StrictHostKeyChecking) {
+		EXIT_ERROR("ERROR: Invalid StrictHostKeyChecking '"+cred.StrictHostKeyChecking+"' for host "+cred.Host+" (must be one of [yes, accept-new, no])", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	cred.UniqID = "GGMCRED_" + strconv.Itoa(i)
+}
+
+// ResolveCredentialValue resolves an 'env:', 'file:' or 'cmd:' reference and decrypts an 'aes2:' (or legacy 'aes:') value of the credentials
+// Plain values in the config are returned with a warning, the result is registered for RedactSecrets
+func (this *GGMConfig) ResolveCredentialValue(value string, name string, host string) string {
+	if value == "" {
+		return ""
+	}
+
+	if IsSecretReference(value) {
+		resolved, err := ResolveSecret(value)
 		if err != nil {
-			EXIT_ERROR("ERROR: The Source '"+this.Remote[i].Source+"' is not a valid URL", EXIT_CONFIG_READ_ERROR)
+			EXIT_ERROR("ERROR: Cannot resolve the "+name+" for host "+host+" ("+value+")\n\n"+err.Error(), EXIT_SECRET_ERROR)
+		}
+		value = resolved
+	} else if !IsEncrypted(value) {
+		LOG_OUT("WARNING: " + name + " for host " + host + " is unencrypted")
+	}
+
+	if IsEncrypted(value) {
+		if strings.HasPrefix(value, CRYPT_PREFIX_LEGACY) {
+			this.LegacySecrets++
 		}
 
-		urlTarget, err := url.Parse(this.Remote[i].Target)
+		plain, err := DecryptConfigValue(value)
 		if err != nil {
-			EXIT_ERROR("ERROR: The Target '"+this.Remote[i].Target+"' is not a valid URL", EXIT_CONFIG_READ_ERROR)
+			EXIT_ERROR("ERROR: Cannot decrypt the "+name+" for host "+host+"\n\n"+err.Error(), EXIT_CRYPT_ERROR)
 		}
+		value = plain
+	}
 
-		if this.Remote[i].SourceCredentials.Host == "" {
-			for _, cred := range this.Credentials {
-				if cred.ID == "" && strings.ToUpper(cred.Host) == strings.ToUpper(urlSource.Host) {
-					this.Remote[i].SourceCredentials = cred
-					this.Remote[i].SourceCredentials.Host = urlSource.Host
-				}
-			}
+	RegisterSecret(value)
+	return value
+}
+
+func (this *GGMConfig) InitRemote(remote *GGMirror) {
+	if remote.Source == "" {
+		EXIT_ERROR("ERROR: Every remote must have the property 'Source' set", EXIT_CONFIG_READ_ERROR)
+	}
+
+	if remote.Target == "" && len(remote.Targets) == 0 {
+		EXIT_ERROR("ERROR: Every remote must have the property 'Target' (or 'Targets') set", EXIT_CONFIG_READ_ERROR)
+	}
+
+	if len(remote.Targets) > 0 {
+		if remote.Target != "" && remote.Target != remote.Targets[0].URL {
+			EXIT_ERROR("ERROR: The remote "+remote.Source+" can only have one of the properties 'Target' and 'Targets' set", EXIT_CONFIG_READ_ERROR)
 		}
-		if this.Remote[i].TargetCredentials.Host == "" {
-			for _, cred := range this.Credentials {
-				if cred.ID == "" && strings.ToUpper(cred.Host) == strings.ToUpper(urlTarget.Host) {
-					this.Remote[i].TargetCredentials = cred
-					this.Remote[i].TargetCredentials.Host = urlTarget.Host
-				}
+		for _, target := range remote.Targets {
+			if target.URL == "" {
+				EXIT_ERROR("ERROR: Every entry in 'Targets' must have the property 'URL' set", EXIT_CONFIG_READ_ERROR)
 			}
+			ValidateRefMap(target.RefMap)
 		}
+		remote.Target = remote.Targets[0].URL // folder, lock and messages are keyed on the first target
+	}
 
-		if this.Remote[i].SourceCredentialsID != "" {
-			this.Remote[i].SourceCredentials = GGCredentials{}
-			for _, cred := range this.Credentials {
-				if cred.ID == this.Remote[i].SourceCredentialsID {
-					this.Remote[i].SourceCredentials = cred
-					this.Remote[i].SourceCredentials.Host = urlSource.Host
-				}
-			}
+	if remote.TempBaseFolder == "" {
+		remote.TempBaseFolder = this.TemporaryPath
+	}
+
+	if remote.PruneLimit == nil {
+		limit := 10
+		remote.PruneLimit = &limit
+	}
+
+	if *remote.PruneLimit < -1 {
+		EXIT_ERROR("ERROR: Invalid PruneLimit "+strconv.Itoa(*remote.PruneLimit)+" of remote "+remote.Source+" (must be -1 or greater)", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	for _, pattern := range append(append([]string{}, remote.IncludeBranches...), remote.ExcludeBranches...) {
+		if err := ValidatePattern(strings.TrimPrefix(pattern, "!")); err != nil {
+			EXIT_ERROR("ERROR: Invalid branch pattern '"+pattern+"'\n\n"+err.Error(), EXIT_CONFIG_VALUE_ERROR)
 		}
-		if this.Remote[i].TargetCredentialsID != "" {
-			this.Remote[i].TargetCredentials = GGCredentials{}
-			for _, cred := range this.Credentials {
-				if cred.ID == this.Remote[i].TargetCredentialsID {
-					this.Remote[i].TargetCredentials = cred
-					this.Remote[i].TargetCredentials.Host = urlTarget.Host
-				}
-			}
+	}
+
+	ValidateRefMap(remote.RefMap)
+
+	if remote.Tags == "" {
+		remote.Tags = TagModeFollow
+	}
+
+	remote.Tags = TagMode(strings.ToLower(string(remote.Tags)))
+
+	if !Contains([]string{string(TagModeNone), string(TagModeFollow), string(TagModeAll), string(TagModeAllPrune), string(TagModeAllForce)}, string(remote.Tags)) {
+		EXIT_ERROR("ERROR: Invalid Tags '"+string(remote.Tags)+"' of remote "+remote.Target+" (must be one of [none, follow, all, all+prune, all+force])", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	if remote.Mode == "" {
+		remote.Mode = MirrorModeBranches
+	}
+
+	remote.Mode = MirrorMode(strings.ToLower(string(remote.Mode)))
+
+	if remote.Mode != MirrorModeBranches && remote.Mode != MirrorModeMirror {
+		EXIT_ERROR("ERROR: Invalid Mode '"+string(remote.Mode)+"' of remote "+remote.Target+" (must be one of [branches, mirror])", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	if remote.DivergencePolicy == "" {
+		remote.DivergencePolicy = this.DivergencePolicy
+	}
+
+	if !ValidateDivergencePolicy(remote.DivergencePolicy) {
+		EXIT_ERROR("ERROR: Invalid DivergencePolicy '"+string(remote.DivergencePolicy)+"' of remote "+remote.Target+" (must be one of [abort, backup, force])", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	if remote.Direction == "" {
+		remote.Direction = DirectionOneWay
+	}
+
+	remote.Direction = SyncDirection(strings.ToLower(string(remote.Direction)))
+
+	if remote.Direction != DirectionOneWay && remote.Direction != DirectionBoth {
+		EXIT_ERROR("ERROR: Invalid Direction '"+string(remote.Direction)+"' of remote "+remote.Target+" (must be one of [oneway, both])", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	if remote.Direction == DirectionBoth {
+		if remote.Mode != MirrorModeBranches || len(remote.Targets) > 1 || len(remote.RefMap) > 0 || remote.PruneTarget || remote.LFS {
+			EXIT_ERROR("ERROR: The remote "+remote.Target+" with Direction = both must have a single target and cannot use Mode = mirror, RefMap, PruneTarget or LFS", EXIT_CONFIG_VALUE_ERROR)
+		}
+		if remote.Tags == TagModeAllPrune || remote.Tags == TagModeAllForce {
+			EXIT_ERROR("ERROR: The remote "+remote.Target+" with Direction = both only supports Tags = none, follow or all", EXIT_CONFIG_VALUE_ERROR)
+		}
+	}
+
+	if remote.Branches == nil {
+		remote.Branches = []string{} // Default value
+		remote.AutoBranchDiscovery = true
+	} else {
+		remote.AutoBranchDiscovery = false
+	}
+
+	if remote.PrimaryBranch == "" {
+		remote.PrimaryBranch = "master"
+	}
+
+	urlSource, err := ParseRepoURL(remote.Source)
+	if err != nil {
+		EXIT_ERROR("ERROR: The Source '"+remote.Source+"' is not a valid URL", EXIT_CONFIG_READ_ERROR)
+	}
+
+	if remote.SourceCredentials.Host == "" {
+		remote.SourceCredentials = this.FindCredentials(urlSource.Host, "")
+	}
+
+	if remote.SourceCredentialsID != "" {
+		remote.SourceCredentials = this.FindCredentials(urlSource.Host, remote.SourceCredentialsID)
+	}
+
+	if len(remote.Targets) == 0 {
+		remote.Targets = []GGMirrorTarget{{URL: remote.Target, CredentialsID: remote.TargetCredentialsID, Credentials: remote.TargetCredentials}}
+	}
+
+	for i := range remote.Targets {
+		target := &remote.Targets[i]
+
+		urlTarget, err := ParseRepoURL(target.URL)
+		if err != nil {
+			EXIT_ERROR("ERROR: The Target '"+target.URL+"' is not a valid URL", EXIT_CONFIG_READ_ERROR)
+		}
+
+		if target.Credentials.Host == "" {
+			target.Credentials = this.FindCredentials(urlTarget.Host, "")
+		}
+		if target.CredentialsID != "" {
+			target.Credentials = this.FindCredentials(urlTarget.Host, target.CredentialsID)
+		}
+	}
+
+	remote.TargetCredentials = remote.Targets[0].Credentials
+}
+
+func ValidateRefMap(refmap []GGRefMapping) {
+	for _, mapping := range refmap {
+		if err := ValidatePattern(mapping.Pattern); err != nil {
+			EXIT_ERROR("ERROR: Invalid RefMap pattern '"+mapping.Pattern+"'\n\n"+err.Error(), EXIT_CONFIG_VALUE_ERROR)
 		}
+		if IsEmpty(mapping.Replacement) {
+			EXIT_ERROR("ERROR: The RefMap pattern '"+mapping.Pattern+"' has no Replacement", EXIT_CONFIG_VALUE_ERROR)
+		}
+	}
+}
+
+func (this *GGMConfig) InitAutoMirrorConfig(amc *GGAutoMirrorConfig) {
+	if amc.Type == "" {
+		EXIT_ERROR("ERROR: Every AutoMirror source and target must have the property 'Type' set", EXIT_CONFIG_READ_ERROR)
+	}
+
+	if !IsForgeTypeRegistered(amc.Type) {
+		EXIT_ERROR("ERROR: Unknown AutoMirror type '"+amc.Type+"' (must be one of ["+strings.Join(RegisteredForgeTypes(), ", ")+"])", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	if amc.RootURL == "" {
+		EXIT_ERROR("ERROR: Every AutoMirror source and target must have the property 'RootURL' set", EXIT_CONFIG_READ_ERROR)
+	}
+
+	if amc.Username == "" {
+		EXIT_ERROR("ERROR: Every AutoMirror source and target must have the property 'Username' set", EXIT_CONFIG_READ_ERROR)
+	}
+
+	urlRoot, err := url.Parse(amc.RootURL)
+	if err != nil {
+		EXIT_ERROR("ERROR: The RootURL '"+amc.RootURL+"' is not a valid URL", EXIT_CONFIG_READ_ERROR)
+	}
+
+	if amc.Credentials.Host == "" {
+		amc.Credentials = this.FindCredentials(urlRoot.Host, amc.CredentialsID)
 	}
 }
 
+// FindCredentials returns the credentials with the given id, or (if id is empty) the anonymous credentials matching the host (with or without port)
+func (this *GGMConfig) FindCredentials(host string, id string) GGCredentials {
+	result := GGCredentials{}
+	for _, cred := range this.Credentials {
+		if id != "" && cred.ID == id {
+			result = cred
+			result.Host = host
+		} else if id == "" && cred.ID == "" && (strings.EqualFold(cred.Host, host) || strings.EqualFold(cred.Host, (RepoURL{Host: host}).Hostname())) {
+			result = cred
+			result.Host = host
+		}
+	}
+	return result
+}
+
+// GetTargetFolder returns the local folder of the remote, keyed on the (first) target
 func (this GGMirror) GetTargetFolder() string {
+	target := this.Target
+	if len(this.Targets) > 0 {
+		target = this.Targets[0].URL // all TargetViews share the same folder
+	}
+
+	name := GetFolderName(target)
+
+	if this.Mode == MirrorModeMirror {
+		name += ".git" // the bare repository must not collide with the working copy of the branches mode
+	}
+
+	return filepath.Join(ExpandPath(this.TempBaseFolder), TEMPFOLDERNAME, name)
+}
+
+// GetFolderName converts a remote url into a folder name (host + path)
+func GetFolderName(remote string) string {
 	var buffer bytes.Buffer
 
-	url, _ := url.Parse(this.Target)
+	url, _ := ParseRepoURL(remote)
 
 	buffer.WriteString(NormalizeStringToFilePath(url.Host))
 
@@ -200,10 +608,26 @@ func (this GGMirror) GetTargetFolder() string {
 		buffer.WriteString(NormalizeStringToFilePath(shatterling))
 	}
 
-	return filepath.Join(ExpandPath(this.TempBaseFolder), TEMPFOLDERNAME, buffer.String())
+	return buffer.String()
+}
+
+func (this GGMirror) NewGitController(folder string) GitController {
+	return GitController{Folder: folder, Bare: this.Mode == MirrorModeMirror, Log: this.Log}
 }
 
-func (this GGMirror) Update(config GGMConfig) {
+// Update mirrors all branches from source to all targets, git errors are reported in the results (one per target) and don't abort the program
+func (this GGMirror) Update(config GGMConfig) []MirrorResult {
+	if this.Mode == MirrorModeMirror {
+		return this.UpdateMirror(config)
+	}
+
+	if this.Direction == DirectionBoth {
+		return this.UpdateBidirectional(config)
+	}
+
+	targets := this.TargetViews()
+	results := this.NewResults(targets)
+
 	folder := this.GetTargetFolder()
 
 	if !PathIsValid(folder) {
@@ -216,49 +640,334 @@ func (this GGMirror) Update(config GGMConfig) {
 		EXIT_ERROR("Cannot create tmp folder '"+folder+"'", EXIT_FILESYSTEM_ACCESS_ERROR)
 	}
 
-	repo := GitController{Folder: folder}
+	repo := this.NewGitController(folder)
+
+	exists, err := repo.ExistsLocal()
+	if err != nil {
+		return FailAll(results, err)
+	}
 
-	if repo.ExistsLocal() {
-		repo.GarbageCollect()
+	if exists {
+		if err := repo.GarbageCollect(); err != nil {
+			return FailAll(results, err)
+		}
 	}
 
-	repo.CloneOrPull(this.PrimaryBranch, this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
+	if err := repo.CloneOrPull(this.PrimaryBranch, this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC); err != nil {
+		return FailAll(results, err)
+	}
+
+	if this.PruneTarget {
+		// otherwise tags that were deleted in the source would be pushed again
+		if err := repo.PruneLocalRefs(this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC); err != nil {
+			return FailAll(results, err)
+		}
+	}
 
 	if config.FastUpdateCheck {
-		repo.FetchAltRemote("orig-source", this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
-		repo.FetchAltRemote("orig-target", this.Target, this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
+		if err := repo.FetchAltRemote("orig-source", this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC); err != nil {
+			return FailAll(results, err)
+		}
+		for i, target := range targets {
+			if err := repo.FetchAltRemote(altTargetRemote(i), target.Target, target.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC); err != nil {
+				return FailAll(results, err)
+			}
+		}
 	}
 
 	if this.AutoBranchDiscovery {
-		this.Branches = repo.ListLocalBranches()
+		branches, err := this.DiscoverBranches(repo)
+		if err != nil {
+			return FailAll(results, err)
+		}
+		this.Branches = branches
 
 		for _, branch := range this.Branches {
-			LOG_OUT("Found branch " + branch + " in source-remote")
+			this.Log.Out("Found branch " + branch + " in source-remote")
+		}
+
+		this.Log.Out("")
+	}
+
+	this.Branches = this.PrimaryFirst(this.Branches)
+
+	sourceRefs := make([]string, 0, len(this.Branches))
+	for _, branch := range this.Branches {
+		sourceRefs = append(sourceRefs, "refs/remotes/origin/"+branch)
+	}
+
+	// before the branches are pushed, some forges reject pushes with missing LFS objects
+	this.MirrorLFS(config, repo, "HEAD", sourceRefs, targets, results)
+
+	if this.Submodules {
+		submodules := this.DiscoverSubmodules(repo, sourceRefs)
+		for i := range results {
+			results[i].Submodules = submodules
 		}
+	}
 
-		LOG_OUT("")
+	mappedFrom := make([]map[string]string, len(targets))
+	for i := range targets {
+		mappedFrom[i] = make(map[string]string)
 	}
 
 	for _, branch := range this.Branches {
-		if config.FastUpdateCheck {
-			LOG_OUT("Fast-Check branch " + branch)
+		pending := make([]int, 0, len(targets)) // the targets that need this branch
 
-			shaLoc := repo.GetHeadHash("orig-source", branch, 40)
-			shaRem := repo.GetHeadHash("orig-target", branch, 40)
+		for i, target := range targets {
+			targetBranch := target.MapBranch(branch)
 
-			if shaLoc != "" && shaRem != "" && shaLoc == shaRem {
-				LOG_OUT("Skip branch " + branch + " (up-to-date with SHA " + shaRem[0:8] + ")")
-				LOG_OUT("")
+			if other, ok := mappedFrom[i][strings.ToLower(targetBranch)]; ok {
+				err := errors.New("The branches " + other + " and " + branch + " are both mapped to " + targetBranch + " in the target (see RefMap)")
+				this.Log.Out(err.Error())
+				results[i].AddBranch(target.DisplayBranch(branch), ResultFailed, err)
 				continue
 			}
+			mappedFrom[i][strings.ToLower(targetBranch)] = branch
+
+			if config.FastUpdateCheck {
+				this.Log.Out("Fast-Check branch " + branch + " on " + target.Target)
+
+				shaLoc := repo.GetHeadHash("orig-source", branch, 40)
+				shaRem := repo.GetHeadHash(altTargetRemote(i), targetBranch, 40)
+
+				if shaLoc != "" && shaRem != "" && shaLoc == shaRem {
+					this.Log.Out("Skip branch " + branch + " (up-to-date with SHA " + shaRem[0:8] + ")")
+					this.Log.Out("")
+					results[i].AddBranch(target.DisplayBranch(branch), ResultUpToDate, nil)
+					if target.PruneTarget {
+						target.RecordPushedRefs([]string{"refs/heads/" + targetBranch})
+					}
+					continue
+				}
+			}
+
+			pending = append(pending, i)
 		}
 
-		LOG_OUT("Getting branch " + branch + " from source-remote")
-		repo.CloneOrPull(branch, this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
+		if len(pending) == 0 {
+			continue
+		}
 
-		LOG_OUT("Pushing branch " + branch + " to target-remote")
-		repo.PushBack(branch, this.Target, this.TargetCredentials, config.CredentialMode, this.Force, config.AutoForceFallback, config.AlwaysCleanNetRC)
+		this.Log.Out("Getting branch " + branch + " from source-remote")
+		err := repo.CloneOrPull(branch, this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
+
+		if err != nil {
+			this.Log.Out("Failed to update branch " + branch + "\n\n" + err.Error())
+			for _, i := range pending {
+				results[i].AddBranch(targets[i].DisplayBranch(branch), ResultFailed, err)
+			}
+			if config.FailFast {
+				return results
+			}
+			continue
+		}
+
+		for _, i := range pending {
+			target := targets[i]
+			targetBranch := target.MapBranch(branch)
+
+			this.Log.Out("Pushing branch " + branch + " to target-remote " + target.Target + " as " + targetBranch)
+			err := repo.PushBack(branch, targetBranch, target.Target, target.TargetCredentials, config.CredentialMode, target.Force, config.AutoForceFallback, config.AlwaysCleanNetRC, target.Tags == TagModeFollow, target.DivergencePolicy, config.GetBackupOptions(target))
+
+			if err != nil {
+				this.Log.Out("Failed to update branch " + branch + " on " + target.Target + "\n\n" + err.Error())
+				results[i].AddBranch(target.DisplayBranch(branch), ResultFailed, err)
+				if config.FailFast {
+					return results
+				}
+				continue
+			}
+
+			results[i].AddBranch(target.DisplayBranch(branch), ResultUpdated, nil)
+			if target.PruneTarget {
+				target.RecordPushedRefs([]string{"refs/heads/" + targetBranch}) // only needed for PruneOnlyMirrored and RefMap
+			}
+		}
+	}
+
+	for i, target := range targets {
+		if target.Tags != TagModeNone && target.Tags != TagModeFollow {
+			target.SyncTags(config, repo, &results[i])
+		}
+
+		if target.PruneTarget {
+			if results[i].Failed() {
+				this.Log.Out("Skip pruning of target-remote " + target.Target + " (not all branches were updated)")
+			} else {
+				target.PruneTargetRefs(config, repo, &results[i])
+			}
+		}
 	}
+
+	return results
+}
+
+// UpdateMirror syncs all refs from source to all targets with a single fetch and one push per target via a bare repository (Mode == mirror)
+func (this GGMirror) UpdateMirror(config GGMConfig) []MirrorResult {
+	targets := this.TargetViews()
+	results := this.NewResults(targets)
+
+	folder := this.GetTargetFolder()
+
+	if !PathIsValid(folder) {
+		EXIT_ERROR("The temporary ggm path is not a valid path '"+folder+"'", EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+
+	err := os.MkdirAll(folder, 0777)
+
+	if err != nil {
+		EXIT_ERROR("Cannot create tmp folder '"+folder+"'", EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+
+	repo := this.NewGitController(folder)
+
+	exists, err := repo.ExistsLocal()
+	if err != nil {
+		return FailAll(results, err)
+	}
+
+	if exists {
+		if err := repo.GarbageCollect(); err != nil {
+			return FailAll(results, err)
+		}
+	}
+
+	this.Log.Out("Fetching all refs from source-remote")
+	if err := repo.MirrorFetch(this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC); err != nil {
+		return FailAll(results, err)
+	}
+
+	// the PrimaryBranch defaults to master, if the source has no such branch we check its default branch instead
+	lfsHead := "refs/heads/" + this.PrimaryBranch
+	if repo.GetRefHash(lfsHead) == "" {
+		if head, err := repo.GetRemoteHead(this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC); err == nil && head != "" {
+			lfsHead = "refs/heads/" + head
+		}
+	}
+
+	this.MirrorLFS(config, repo, lfsHead, nil, targets, results)
+
+	if this.Submodules {
+		branches, err := this.DiscoverBranches(repo)
+		if err != nil {
+			return FailAll(results, err)
+		}
+
+		refs := make([]string, 0, len(branches))
+		for _, branch := range branches {
+			refs = append(refs, "refs/heads/"+branch)
+		}
+
+		submodules := this.DiscoverSubmodules(repo, refs)
+		for i := range results {
+			results[i].Submodules = submodules
+		}
+	}
+
+	for i, target := range targets {
+		this.Log.Out("Pushing all refs to target-remote " + target.Target)
+		refs, err := repo.MirrorPush(target.Target, this.MirrorRefSpecs, target.TargetCredentials, config.CredentialMode, target.Force, config.AutoForceFallback, config.AlwaysCleanNetRC, this.DivergencePolicy, config.GetBackupOptions(target))
+		if err != nil {
+			this.Log.Out("Failed to mirror refs\n\n" + err.Error())
+			results[i].Error = err
+			if config.FailFast {
+				return results
+			}
+			continue
+		}
+
+		for _, ref := range refs {
+			results[i].AddBranch(ref, ResultUpdated, nil)
+		}
+
+		if len(refs) == 0 {
+			results[i].AddBranch("*", ResultUpToDate, nil)
+		}
+	}
+
+	return results
+}
+
+// MapBranch returns the name of the branch in the target (see RefMap), this also applies to the PrimaryBranch (see PrimaryFirst)
+func (this GGMirror) MapBranch(branch string) string {
+	for _, mapping := range this.RefMap {
+		if mapped, ok := MapPattern(mapping.Pattern, mapping.Replacement, branch); ok {
+			return mapped
+		}
+	}
+	return branch
+}
+
+// PrimaryFirst returns a copy of branches with the PrimaryBranch in front
+// The first push into an empty target creates the (mapped) PrimaryBranch, so it becomes the default branch of the target
+func (this GGMirror) PrimaryFirst(branches []string) []string {
+	result := make([]string, 0, len(branches))
+	for _, branch := range branches {
+		if branch == this.PrimaryBranch {
+			result = append(result, branch)
+		}
+	}
+	for _, branch := range branches {
+		if branch != this.PrimaryBranch {
+			result = append(result, branch)
+		}
+	}
+	return result
+}
+
+// DisplayBranch returns 'source -> target' for mapped branches and the plain name otherwise
+func (this GGMirror) DisplayBranch(branch string) string {
+	if targetBranch := this.MapBranch(branch); targetBranch != branch {
+		return branch + " -> " + targetBranch
+	}
+	return branch
+}
+
+// DiscoverBranches lists the branches of the source (in the local repository), filtered by IncludeBranches/ExcludeBranches
+func (this GGMirror) DiscoverBranches(repo GitController) ([]string, error) {
+	branches, err := repo.ListLocalBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	return this.FilterBranches(branches), nil
+}
+
+// FilterBranches applies IncludeBranches and ExcludeBranches to a list of branch names
+func (this GGMirror) FilterBranches(branches []string) []string {
+	result := make([]string, 0, len(branches))
+	for _, branch := range branches {
+		if this.IsBranchIncluded(branch) {
+			result = append(result, branch)
+		}
+	}
+	return result
+}
+
+// IsBranchIncluded returns false if the branch matches an exclude pattern or if there are include patterns and none of them matches
+func (this GGMirror) IsBranchIncluded(branch string) bool {
+	include := make([]string, 0, len(this.IncludeBranches))
+	exclude := append([]string{}, this.ExcludeBranches...)
+
+	for _, pattern := range this.IncludeBranches {
+		if strings.HasPrefix(pattern, "!") {
+			exclude = append(exclude, pattern[1:])
+		} else {
+			include = append(include, pattern)
+		}
+	}
+
+	if _, ok := MatchAnyPattern(exclude, branch); ok {
+		return false
+	}
+
+	if len(include) == 0 {
+		return true
+	}
+
+	_, ok := MatchAnyPattern(include, branch)
+	return ok
 }
 
 func (this GGMirror) CleanFolder() {
@@ -289,33 +998,36 @@ func (this GGMirror) OutputStatus(config GGMConfig) {
 
 	valName := forceStrLen(this.GetShortName(), STAT_COL_NAME)
 
+	fastUpdateCheck := config.FastUpdateCheck && this.Mode != MirrorModeMirror // alternative remotes would end up in the bare mirror
+
 	if this.AutoBranchDiscovery {
 
-		repo := GitController{Folder: folderLocal}
+		repo := this.NewGitController(folderLocal)
 		repo.SetSilent()
 
-		if repo.ExistsLocal() {
+		if exists, _ := repo.ExistsLocal(); exists {
 
-			if config.FastUpdateCheck {
-				repo.FetchAltRemote("orig-source", this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
-				repo.FetchAltRemote("orig-target", this.Target, this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
+			if fastUpdateCheck {
+				_ = repo.FetchAltRemote("orig-source", this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
+				_ = repo.FetchAltRemote("orig-target", this.Target, this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
 			}
 
-			this.Branches = repo.ListLocalBranches()
+			this.Branches, _ = this.DiscoverBranches(repo)
+			this.Branches = this.PrimaryFirst(this.Branches)
 
 			if len(this.Branches) > 0 {
 
-				if config.FastUpdateCheck {
+				if fastUpdateCheck {
 					for _, branch := range this.Branches {
-						valBranch := forceStrLen(branch, STAT_COL_BRANCH)
+						valBranch := forceStrLen(this.DisplayBranch(branch), STAT_COL_BRANCH)
 						valSource := forceStrLen(repo.GetHeadHash("orig-source", branch, 8), STAT_COL_SOURCE)
 						valLocal := forceStrLen(this.GetStatusLocal(branch, 8), STAT_COL_LOCAL)
-						valRemote := forceStrLen(repo.GetHeadHash("orig-target", branch, 8), STAT_COL_TARGET)
+						valRemote := forceStrLen(repo.GetHeadHash("orig-target", this.MapBranch(branch), 8), STAT_COL_TARGET)
 						LOG_OUT(diff(valSource, valLocal, valRemote, "X", " ") + "| " + valName + "| " + valBranch + "| " + valSource + " | " + valLocal + " | " + valRemote)
 					}
 				} else {
 					for _, branch := range this.Branches {
-						valBranch := forceStrLen(branch, STAT_COL_BRANCH)
+						valBranch := forceStrLen(this.DisplayBranch(branch), STAT_COL_BRANCH)
 						valSource := forceStrLen(this.GetStatusSource(config, branch, 8), STAT_COL_SOURCE)
 						valLocal := forceStrLen(this.GetStatusLocal(branch, 8), STAT_COL_LOCAL)
 						valRemote := forceStrLen(this.GetStatusRemote(config, branch, 8), STAT_COL_TARGET)
@@ -337,8 +1049,8 @@ func (this GGMirror) OutputStatus(config GGMConfig) {
 			LOG_OUT("X| " + valName + "| " + valBranch + "| " + valSource + " | " + valLocal + " | " + valRemote)
 		}
 	} else {
-		for _, branch := range this.Branches {
-			valBranch := forceStrLen(branch, STAT_COL_BRANCH)
+		for _, branch := range this.PrimaryFirst(this.Branches) {
+			valBranch := forceStrLen(this.DisplayBranch(branch), STAT_COL_BRANCH)
 			valSource := forceStrLen(this.GetStatusSource(config, branch, 8), STAT_COL_SOURCE)
 			valLocal := forceStrLen(this.GetStatusLocal(branch, 8), STAT_COL_LOCAL)
 			valRemote := forceStrLen(this.GetStatusRemote(config, branch, 8), STAT_COL_TARGET)
@@ -347,12 +1059,20 @@ func (this GGMirror) OutputStatus(config GGMConfig) {
 	}
 }
 
+// Matches returns true if search (lowercase) is the id, the source or one of the targets of the remote
+func (this GGMirror) Matches(search string) bool {
+	return search != "" && (strings.ToLower(this.ID) == search || strings.ToLower(this.Source) == search || this.HasTarget(search))
+}
+
 func (this GGMirror) GetShortName() string {
 	shatterlings := strings.Split(strings.Trim(this.Source, "/"), "/")
 	sn := shatterlings[len(shatterlings)-1]
 	if strings.HasSuffix(strings.ToLower(sn), ".git") {
 		sn = sn[:len(sn)-4]
 	}
+	if len(this.Targets) > 1 {
+		sn += " @ " + this.targetHost()
+	}
 	return sn
 }
 
@@ -363,10 +1083,10 @@ func (this GGMirror) GetStatusLocal(branch string, hashlen int) string {
 		return "N/A"
 	}
 
-	repo := GitController{Folder: folder}
+	repo := this.NewGitController(folder)
 	repo.SetSilent()
 
-	if !repo.ExistsLocal() {
+	if exists, err := repo.ExistsLocal(); err != nil || !exists {
 		return "N/A"
 	}
 
@@ -388,7 +1108,7 @@ func (this GGMirror) GetStatusSource(config GGMConfig, branch string, hashlen in
 }
 
 func (this GGMirror) GetStatusRemote(config GGMConfig, branch string, hashlen int) string {
-	return this.GetStatus(config, this.Target, this.TargetCredentials, branch, hashlen)
+	return this.GetStatus(config, this.Target, this.TargetCredentials, this.MapBranch(branch), hashlen)
 }
 
 func (this GGMirror) GetStatus(config GGMConfig, url string, cred GGCredentials, branch string, hashlen int) string {
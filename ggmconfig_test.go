@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestMapBranch(t *testing.T) {
+	mirror := GGMirror{
+		PrimaryBranch: "main",
+		RefMap: []GGRefMapping{
+			{Pattern: "main", Replacement: "upstream/main"},
+			{Pattern: "regex:^feature-(.*)$", Replacement: "feature/$1"},
+			{Pattern: "feature-*", Replacement: "never/*"},
+			{Pattern: "release/*", Replacement: "mirror/release/*"},
+		},
+	}
+
+	tests := map[string]string{
+		"main":          "upstream/main", // also the PrimaryBranch
+		"feature-login": "feature/login", // the first matching mapping wins
+		"release/1.0":   "mirror/release/1.0",
+		"develop":       "develop",  // unmatched branches keep their name
+		"mainline":      "mainline", // globs are anchored
+	}
+
+	for branch, expected := range tests {
+		if mapped := mirror.MapBranch(branch); mapped != expected {
+			t.Errorf("MapBranch(%q) = %q, expected %q", branch, mapped, expected)
+		}
+	}
+
+	if display := mirror.DisplayBranch("main"); display != "main -> upstream/main" {
+		t.Errorf("unexpected DisplayBranch %q", display)
+	}
+	if display := mirror.DisplayBranch("develop"); display != "develop" {
+		t.Errorf("unexpected DisplayBranch %q", display)
+	}
+}
+
+func TestPrimaryFirst(t *testing.T) {
+	mirror := GGMirror{PrimaryBranch: "main"}
+
+	branches := []string{"develop", "main", "release/1.0"}
+	result := mirror.PrimaryFirst(branches)
+
+	if len(result) != 3 || result[0] != "main" || result[1] != "develop" || result[2] != "release/1.0" {
+		t.Errorf("unexpected order %v", result)
+	}
+	if branches[0] != "develop" {
+		t.Errorf("PrimaryFirst must not modify its argument")
+	}
+
+	if result := mirror.PrimaryFirst([]string{"develop"}); len(result) != 1 || result[0] != "develop" {
+		t.Errorf("unexpected result without the PrimaryBranch %v", result)
+	}
+}
@@ -1,53 +1,216 @@
 package main
 
 import (
+	"bufio"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
 )
 
-// encrypt string to base64 crypto using AES
-func Encrypt(text string) string {
-	key := []byte(SALT)
-	plaintext := []byte(text)
+// Secrets in the config are encrypted with AES-GCM ('aes2:' prefix), the AES key is derived with scrypt from the crypt key:
+//   1. the environment variable GOGITMIRROR_KEY
+//   2. the content of the file in GOGITMIRROR_KEYFILE (or of ~/.config/gogitmirror.key)
+//   3. a passphrase entered on the terminal
+// Values with the legacy 'aes:' prefix (AES-CFB with the compiled-in SALT) can still be read, 'crypt --rekey' converts them
 
-	block, err := aes.NewCipher(key)
+const CRYPT_PREFIX = "aes2:"
+const CRYPT_PREFIX_LEGACY = "aes:"
+
+const cryptSaltSize = 16
+
+var cryptKey string
+
+// IsEncrypted returns true if the value has one of the encryption prefixes
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, CRYPT_PREFIX) || strings.HasPrefix(value, CRYPT_PREFIX_LEGACY)
+}
+
+// GetCryptKey returns the crypt key from the environment or the key file, if neither exists the passphrase is read from the terminal
+func GetCryptKey() (string, error) {
+	if cryptKey != "" {
+		return cryptKey, nil
+	}
+
+	key, err := ReadCryptKey(os.Getenv(ENV_CRYPT_KEY), os.Getenv(ENV_CRYPT_KEYFILE), ExpandPath(KEYFILE_PATH), "Passphrase: ")
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	// The IV needs to be unique, but not secure. Therefore it's common to
-	// include it at the beginning of the ciphertext.
-	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		panic(err)
+	cryptKey = key
+	return key, nil
+}
+
+// CryptKeyEnv returns the environment variable with the crypt key (if it was needed), it is only passed to the git commands
+// that start this binary again as credential helper or SSH_ASKPASS (they cannot ask for the passphrase)
+func CryptKeyEnv() []string {
+	if cryptKey == "" {
+		return nil
+	}
+	return []string{ENV_CRYPT_KEY + "=" + cryptKey}
+}
+
+// ReadCryptKey returns the key, the content of the key file, the content of the default key file or a passphrase from the terminal (in this order)
+func ReadCryptKey(key string, keyfile string, defaultKeyfile string, prompt string) (string, error) {
+	if key != "" {
+		return key, nil
+	}
+
+	if keyfile == "" && defaultKeyfile != "" && PathExists(defaultKeyfile) {
+		keyfile = defaultKeyfile
+	}
+
+	if keyfile != "" {
+		content, err := ioutil.ReadFile(ExpandPath(keyfile))
+		if err != nil {
+			return "", errors.New("Cannot read the key file '" + keyfile + "'\n\n" + err.Error())
+		}
+		key = strings.TrimSpace(string(content))
+		if key == "" {
+			return "", errors.New("The key file '" + keyfile + "' is empty")
+		}
+		return key, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("No crypt key found, set " + ENV_CRYPT_KEY + " or " + ENV_CRYPT_KEYFILE + " or create the key file " + KEYFILE_PATH)
+	}
+
+	return ReadPassphrase(prompt)
+}
+
+// ReadPassphrase reads a line from the terminal without echoing it
+func ReadPassphrase(prompt string) (string, error) {
+	os.Stderr.WriteString(prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	os.Stderr.WriteString("\n")
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("The passphrase must not be empty")
+	}
+	return string(passphrase), nil
+}
+
+func deriveCryptKey(key string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(key), salt, 1<<15, 8, 1, 32)
+}
+
+// EncryptSecret encrypts text with AES-GCM, the result is 'aes2:' + base64(salt | nonce | ciphertext)
+func EncryptSecret(key string, text string) (string, error) {
+	salt := make([]byte, cryptSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", err
+	}
+
+	gcm, err := newCryptGCM(key, salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	data := append(append(salt, nonce...), gcm.Seal(nil, nonce, []byte(text), nil)...)
+
+	return CRYPT_PREFIX + base64.URLEncoding.EncodeToString(data), nil
+}
+
+// DecryptSecret decrypts an 'aes2:' or legacy 'aes:' value, returns an error if the key is wrong or the value was modified
+func DecryptSecret(key string, value string) (string, error) {
+	if strings.HasPrefix(value, CRYPT_PREFIX_LEGACY) {
+		return DecryptLegacy(value[len(CRYPT_PREFIX_LEGACY):])
+	}
+
+	if !strings.HasPrefix(value, CRYPT_PREFIX) {
+		return "", errors.New("The value is not encrypted")
+	}
+
+	data, err := base64.URLEncoding.DecodeString(value[len(CRYPT_PREFIX):])
+	if err != nil {
+		return "", errors.New("The encrypted value is not valid base64")
 	}
 
-	stream := cipher.NewCFBEncrypter(block, iv)
-	stream.XORKeyStream(ciphertext[aes.BlockSize:], plaintext)
+	if len(data) < cryptSaltSize {
+		return "", errors.New("The encrypted value is too short")
+	}
+
+	gcm, err := newCryptGCM(key, data[:cryptSaltSize])
+	if err != nil {
+		return "", err
+	}
 
-	// convert to base64
-	return base64.URLEncoding.EncodeToString(ciphertext)
+	data = data[cryptSaltSize:]
+	if len(data) < gcm.NonceSize()+gcm.Overhead() {
+		return "", errors.New("The encrypted value is too short")
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("Cannot decrypt the value (wrong key or modified value)")
+	}
+
+	return string(plaintext), nil
 }
 
-// decrypt from base64 to decrypted string
-func Decrypt(cryptoText string) string {
+// DecryptConfigValue decrypts a value of the config (the key is only needed for 'aes2:' values)
+func DecryptConfigValue(value string) (string, error) {
+	if strings.HasPrefix(value, CRYPT_PREFIX_LEGACY) {
+		return DecryptLegacy(value[len(CRYPT_PREFIX_LEGACY):])
+	}
+
+	key, err := GetCryptKey()
+	if err != nil {
+		return "", err
+	}
+
+	return DecryptSecret(key, value)
+}
+
+func newCryptGCM(key string, salt []byte) (cipher.AEAD, error) {
+	derived, err := deriveCryptKey(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// DecryptLegacy decrypts the base64 part of an 'aes:' value (AES-CFB with SALT, without integrity check)
+func DecryptLegacy(cryptoText string) (string, error) {
 	key := []byte(SALT)
-	ciphertext, _ := base64.URLEncoding.DecodeString(cryptoText)
+	ciphertext, err := base64.URLEncoding.DecodeString(cryptoText)
+	if err != nil {
+		return "", errors.New("The encrypted value is not valid base64")
+	}
 
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
 	// The IV needs to be unique, but not secure. Therefore it's common to
 	// include it at the beginning of the ciphertext.
 	if len(ciphertext) < aes.BlockSize {
-		panic("ciphertext too short")
+		return "", errors.New("The encrypted value is too short")
 	}
 	iv := ciphertext[:aes.BlockSize]
 	ciphertext = ciphertext[aes.BlockSize:]
@@ -57,5 +220,95 @@ func Decrypt(cryptoText string) string {
 	// XORKeyStream can work in-place if the two arguments are the same.
 	stream.XORKeyStream(ciphertext, ciphertext)
 
-	return fmt.Sprintf("%s", ciphertext)
+	return fmt.Sprintf("%s", ciphertext), nil
+}
+
+// RekeyConfig re-encrypts every 'aes2:' and 'aes:' value in the config text with the new key, returns the new text and the number of values
+// Values in basic ("...") and literal ('...') strings are converted, multi-line strings are not supported
+func RekeyConfig(content string, oldKey func() (string, error), newKey string) (string, int, error) {
+	var buffer strings.Builder
+	count := 0
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		for _, quoted := range findEncryptedValues(line) {
+			quote := quoted[:1]
+			value := quoted[1 : len(quoted)-1]
+
+			key := ""
+			if strings.HasPrefix(value, CRYPT_PREFIX) {
+				k, err := oldKey()
+				if err != nil {
+					return "", 0, err
+				}
+				key = k
+			}
+
+			plain, err := DecryptSecret(key, value)
+			if err != nil {
+				return "", 0, errors.New("Cannot decrypt '" + value + "'\n\n" + err.Error())
+			}
+
+			encrypted, err := EncryptSecret(newKey, plain)
+			if err != nil {
+				return "", 0, err
+			}
+
+			line = strings.Replace(line, quoted, quote+encrypted+quote, 1)
+			count++
+		}
+
+		buffer.WriteString(line + "\n")
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", 0, err
+	}
+
+	return buffer.String(), count, nil
+}
+
+// WarnLegacySecrets prints a warning if the config contains values with the legacy 'aes:' encryption
+func (this GGMConfig) WarnLegacySecrets() {
+	if this.LegacySecrets > 0 {
+		LOG_OUT("WARNING: " + strconv.Itoa(this.LegacySecrets) + " password(s) use the legacy 'aes:' encryption, which anybody with the binary can decrypt (use 'crypt --rekey')")
+		LOG_LINESEP()
+	}
+}
+
+// findEncryptedValues returns the quoted strings (including the quotes) of the line whose value starts with an encryption prefix (comments are ignored)
+func findEncryptedValues(line string) []string {
+	result := make([]string, 0)
+
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' {
+			break // the rest of the line is a comment
+		}
+		if line[i] != '"' && line[i] != '\'' {
+			continue
+		}
+
+		quote := line[i]
+		end := i + 1
+		for end < len(line) && line[end] != quote {
+			if quote == '"' && line[end] == '\\' {
+				end++ // escaped character in a basic string
+			}
+			end++
+		}
+		if end >= len(line) {
+			break // unterminated (or multi-line) string
+		}
+
+		if IsEncrypted(line[i+1 : end]) {
+			result = append(result, line[i:end+1])
+		}
+		i = end
+	}
+
+	return result
 }
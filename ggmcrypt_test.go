@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// encryptLegacy creates an 'aes:' value like old versions did (AES-CFB with SALT as key)
+func encryptLegacy(t *testing.T, text string) string {
+	block, err := aes.NewCipher([]byte(SALT))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, aes.BlockSize+len(text))
+	iv := data[:aes.BlockSize]
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+	cipher.NewCFBEncrypter(block, iv).XORKeyStream(data[aes.BlockSize:], []byte(text))
+
+	return CRYPT_PREFIX_LEGACY + base64.URLEncoding.EncodeToString(data)
+}
+
+func TestEncryptSecretRoundTrip(t *testing.T) {
+	for _, text := range []string{"hunter12", "", "pässwörd with spaces \" and ' quotes"} {
+		value, err := EncryptSecret("key1", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(value, CRYPT_PREFIX) || !IsEncrypted(value) {
+			t.Errorf("unexpected encrypted value %q", value)
+		}
+		if strings.Contains(value, text) && text != "" {
+			t.Errorf("the encrypted value contains the plain text")
+		}
+
+		plain, err := DecryptSecret("key1", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain != text {
+			t.Errorf("expected %q, got %q", text, plain)
+		}
+	}
+
+	a, _ := EncryptSecret("key1", "hunter12")
+	b, _ := EncryptSecret("key1", "hunter12")
+	if a == b {
+		t.Errorf("two encryptions of the same text must differ (random salt and nonce)")
+	}
+}
+
+func TestDecryptSecretWrongKey(t *testing.T) {
+	value, err := EncryptSecret("key1", "hunter12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plain, err := DecryptSecret("key2", value); err == nil {
+		t.Errorf("expected an error with the wrong key, got %q", plain)
+	}
+}
+
+func TestDecryptSecretTampered(t *testing.T) {
+	value, err := EncryptSecret("key1", "hunter12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := base64.URLEncoding.DecodeString(value[len(CRYPT_PREFIX):])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pos := range []int{0, cryptSaltSize, len(data) - 1} {
+		modified := append([]byte{}, data...)
+		modified[pos] ^= 0x01
+		if plain, err := DecryptSecret("key1", CRYPT_PREFIX+base64.URLEncoding.EncodeToString(modified)); err == nil {
+			t.Errorf("expected an error for a modified byte at %d, got %q", pos, plain)
+		}
+	}
+
+	if _, err := DecryptSecret("key1", CRYPT_PREFIX+base64.URLEncoding.EncodeToString(data[:cryptSaltSize+4])); err == nil {
+		t.Errorf("expected an error for a truncated value")
+	}
+	if _, err := DecryptSecret("key1", CRYPT_PREFIX+"not base64!"); err == nil {
+		t.Errorf("expected an error for invalid base64")
+	}
+	if _, err := DecryptSecret("key1", "hunter12"); err == nil {
+		t.Errorf("expected an error for an unencrypted value")
+	}
+}
+
+func TestDecryptSecretLegacy(t *testing.T) {
+	value := encryptLegacy(t, "hunter12")
+
+	if !IsEncrypted(value) {
+		t.Errorf("legacy values must be recognized as encrypted")
+	}
+
+	// legacy values do not need the key
+	for _, key := range []string{"", "key1"} {
+		plain, err := DecryptSecret(key, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain != "hunter12" {
+			t.Errorf("expected 'hunter12', got %q", plain)
+		}
+	}
+}
+
+func TestFindEncryptedValues(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{`Password = "aes2:abc"`, []string{`"aes2:abc"`}},
+		{`Password = 'aes2:abc'`, []string{`'aes2:abc'`}},
+		{`Password = "plain"`, []string{}},
+		{`Password = "aes2:abc" # was "aes2:old"`, []string{`"aes2:abc"`}},
+		{`# Password = "aes2:abc"`, []string{}},
+		{`Value = "a \"quoted\" # text", Password = "aes:xyz"`, []string{`"aes:xyz"`}},
+		{`Value = 'it"s', Password = "aes2:abc"`, []string{`"aes2:abc"`}},
+		{`Password = "aes2:abc`, []string{}},
+	}
+
+	for _, test := range tests {
+		result := findEncryptedValues(test.line)
+		if len(result) != len(test.expected) {
+			t.Errorf("findEncryptedValues(%q) = %v, expected %v", test.line, result, test.expected)
+			continue
+		}
+		for i := range result {
+			if result[i] != test.expected[i] {
+				t.Errorf("findEncryptedValues(%q) = %v, expected %v", test.line, result, test.expected)
+			}
+		}
+	}
+}
+
+func TestRekeyConfig(t *testing.T) {
+	double, _ := EncryptSecret("old", "secret-double")
+	single, _ := EncryptSecret("old", "secret-single")
+	escaped, _ := EncryptSecret("old", "secret-escaped")
+	commented, _ := EncryptSecret("old", "secret-comment")
+	legacy := encryptLegacy(t, "secret-legacy")
+
+	content := "[[Credentials]]\n" +
+		"Password = \"" + double + "\"\n" +
+		"Token = '" + single + "'\n" +
+		"Username = \"a \\\"b\\\" c\", Password = \"" + escaped + "\"\n" +
+		"SSHKeyPassphrase = \"" + legacy + "\"  # old: \"" + commented + "\"\n" +
+		"Host = \"example.com\"\n"
+
+	oldKeyCalls := 0
+	result, count, err := RekeyConfig(content, func() (string, error) { oldKeyCalls++; return "old", nil }, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 4 {
+		t.Errorf("expected 4 re-encrypted values, got %d", count)
+	}
+	if oldKeyCalls == 0 {
+		t.Errorf("the old key was never requested")
+	}
+
+	lines := strings.Split(result, "\n")
+	if lines[0] != "[[Credentials]]" || lines[5] != `Host = "example.com"` {
+		t.Errorf("lines without encrypted values must not change:\n%s", result)
+	}
+	if !strings.HasPrefix(lines[3], `Username = "a \"b\" c", Password = "aes2:`) {
+		t.Errorf("unexpected line with escaped quotes: %s", lines[3])
+	}
+	if !strings.HasPrefix(lines[2], "Token = 'aes2:") || !strings.HasSuffix(lines[2], "'") {
+		t.Errorf("the literal string must keep its quotes: %s", lines[2])
+	}
+	if !strings.HasSuffix(lines[4], `# old: "`+commented+`"`) {
+		t.Errorf("values in comments must not change: %s", lines[4])
+	}
+
+	expected := map[int]string{1: "secret-double", 2: "secret-single", 3: "secret-escaped", 4: "secret-legacy"}
+	for idx, plain := range expected {
+		values := findEncryptedValues(lines[idx])
+		if len(values) != 1 {
+			t.Errorf("expected one encrypted value in line %d: %s", idx, lines[idx])
+			continue
+		}
+		value := values[0][1 : len(values[0])-1]
+		if !strings.HasPrefix(value, CRYPT_PREFIX) {
+			t.Errorf("line %d was not converted to %s: %s", idx, CRYPT_PREFIX, lines[idx])
+		}
+		if decrypted, err := DecryptSecret("new", value); err != nil || decrypted != plain {
+			t.Errorf("line %d: expected %q with the new key, got %q (%v)", idx, plain, decrypted, err)
+		}
+		if _, err := DecryptSecret("old", value); err == nil {
+			t.Errorf("line %d can still be decrypted with the old key", idx)
+		}
+	}
+
+	if _, _, err := RekeyConfig("Password = \""+double+"\"\n", func() (string, error) { return "wrong", nil }, "new"); err == nil {
+		t.Errorf("expected an error with the wrong old key")
+	}
+}
@@ -1,90 +1,127 @@
 package main
 
 import (
+	"errors"
+	"strconv"
 	"strings"
 )
 
 type GitController struct {
 	Folder string
 	Silent bool
+	Bare   bool       // the repository in Folder is a bare mirror (GGMirror.Mode == mirror)
+	Log    *LogBuffer // nil logs directly to stdout
 }
 
 func (this *GitController) SetSilent() {
 	this.Silent = true
 }
 
-func (this *GitController) ExistsLocal() bool {
+// ExistsLocal returns true if the folder contains a (bare) repository, the error is only set if git could not be executed
+func (this *GitController) ExistsLocal() (bool, error) {
 
 	if !PathExists(this.Folder) {
-		return false
+		return false, nil
 	}
+
+	if this.Bare {
+		exitcode, stdout, _, err := this.ExecGitCommandErr(false, "rev-parse", "--is-bare-repository")
+
+		if err != nil {
+			return false, errors.New("Error executing command 'git rev-parse'\n\n" + err.Error())
+		}
+
+		return exitcode == 0 && strings.TrimSpace(stdout) == "true", nil
+	}
+
 	exitcode, _, _, err := this.ExecGitCommandErr(false, "status")
 
 	if err != nil {
-		EXIT_ERROR("Error executing command 'git status'\n\n"+err.Error(), EXIT_GIT_ERROR)
+		return false, errors.New("Error executing command 'git status'\n\n" + err.Error())
 	}
 
-	return exitcode == 0
+	return exitcode == 0, nil
 }
 
 func (this *GitController) ExecGitCommandSafe(nosslverify bool, args ...string) (int, string, string) {
-	exitcode, stdout, stderr, err := this.ExecGitCommandErr(nosslverify, args...)
+	return this.ExecGitCommandEnvSafe(nil, nosslverify, args...)
+}
+
+// ExecGitCommandEnvSafe is ExecGitCommandSafe with additional environment variables (e.g. GGCredentials.GitEnv)
+func (this *GitController) ExecGitCommandEnvSafe(env []string, nosslverify bool, args ...string) (int, string, string) {
+	exitcode, stdout, stderr, err := this.ExecGitCommandEnvErr(env, nosslverify, args...)
 
 	if err != nil {
 		exitcode = -1
 		stderr = "Recoverable Error executing command 'git " + args[0] + "'\n\n" + err.Error()
-		LOG_OUT("Recoverable Internal Error in command 'git " + args[0] + "'\n\n" + stderr)
+		this.Log.Out("Recoverable Internal Error in command 'git " + args[0] + "'\n\n" + stderr)
 	} else if exitcode != 0 {
-		LOG_OUT("Recoverable Error in command 'git " + args[0] + "'\n\n" + stderr)
+		this.Log.Out("Recoverable Error in command 'git " + args[0] + "'\n\n" + stderr)
 	}
 
 	return exitcode, stdout, stderr
 }
 
 func (this *GitController) ExecGitCommandErr(nosslverify bool, args ...string) (int, string, string, error) {
+	return this.ExecGitCommandEnvErr(nil, nosslverify, args...)
+}
+
+// ExecGitCommandEnvErr is ExecGitCommandErr with additional environment variables (e.g. GGCredentials.GitEnv)
+func (this *GitController) ExecGitCommandEnvErr(env []string, nosslverify bool, args ...string) (int, string, string, error) {
 	allargs := args
 	if nosslverify {
 		allargs = append([]string{"-c", "http.sslVerify=false"}, allargs...)
 	}
-	return CmdRun(this.Folder, this.Silent, "git", allargs...)
+	if !this.Silent {
+		this.Log.Out("   > git " + Join(" ", allargs))
+	}
+	return CmdRunEnv(this.Folder, true, env, "git", allargs...)
+}
+
+func (this *GitController) ExecGitCommand(nosslverify bool, args ...string) (string, error) {
+	return this.ExecGitCommandEnv(nil, nosslverify, args...)
 }
 
-func (this *GitController) ExecGitCommand(nosslverify bool, args ...string) string {
-	exitcode, stdout, stderr, err := this.ExecGitCommandErr(nosslverify, args...)
+// ExecGitCommandEnv is ExecGitCommand with additional environment variables (e.g. GGCredentials.GitEnv)
+func (this *GitController) ExecGitCommandEnv(env []string, nosslverify bool, args ...string) (string, error) {
+	exitcode, stdout, stderr, err := this.ExecGitCommandEnvErr(env, nosslverify, args...)
 
 	if err != nil {
-		EXIT_ERROR("Error executing command 'git "+args[0]+"'\n\n"+err.Error(), EXIT_GIT_ERROR)
+		return stdout, errors.New("Error executing command 'git " + args[0] + "'\n\n" + err.Error())
 	}
 
 	if exitcode != 0 {
-		EXIT_ERROR("Error in command 'git "+args[0]+"'\n\n"+stderr, EXIT_GIT_ERROR)
+		return stdout, errors.New("Error in command 'git " + args[0] + "'\n\n" + stderr)
 	}
 
-	return stdout
+	return stdout, nil
 }
 
 func (this *GitController) ExecCredGitCommandSafe(cred GGCredentials, mode CredMode, forceNetRCClean bool, nosslverify bool, args ...string) (int, string, string) {
 
+	env := cred.GitEnv()
+
 	if IsEmpty(cred.Host) || IsEmpty(cred.Username) || IsEmpty(cred.Password) {
-		return this.ExecGitCommandSafe(nosslverify, args...)
+		return this.ExecGitCommandEnvSafe(env, nosslverify, args...)
 	}
 
 	if mode == CredModeNetRC {
 		EnterNetRCBlock(cred.Host, cred.Username, cred.Password)
-		exitcode, stdout, stderr := this.ExecGitCommandSafe(nosslverify, args...)
+		exitcode, stdout, stderr := this.ExecGitCommandEnvSafe(env, nosslverify, args...)
 		ExitNetRCBlock(forceNetRCClean)
 		return exitcode, stdout, stderr
 	} else if mode == CredModeHelper {
-		gitargs := []string{"-c", "credential.helper=\"!" + BINARY_PATH + " credentials " + cred.UniqID + "\""}
+		env = append(env, CryptKeyEnv()...) // the credential helper loads the config again
+		gitargs := []string{"-c", "credential.helper=!" + shellQuote(BINARY_PATH) + " credentials " + cred.UniqID}
 		gitargs = append(gitargs, args...)
-		exitcode, stdout, stderr := this.ExecGitCommandSafe(nosslverify, gitargs...)
+		exitcode, stdout, stderr := this.ExecGitCommandEnvSafe(env, nosslverify, gitargs...)
 		return exitcode, stdout, stderr
 	} else if mode == CredModeCFile {
 		tf, cleanup := CreateCredTempFile(cred.Host, cred.Username, cred.Password)
 		defer cleanup()
 		gitargs := []string{"-c", "credential.helper=store --file " + tf}
 		gitargs = append(gitargs, args...)
-		exitcode, stdout, stderr := this.ExecGitCommandSafe(nosslverify, gitargs...)
+		exitcode, stdout, stderr := this.ExecGitCommandEnvSafe(env, nosslverify, gitargs...)
 		return exitcode, stdout, stderr
 	}
 
@@ -92,72 +129,121 @@ func (this *GitController) ExecCredGitCommandSafe(cred GGCredentials, mode CredM
 	return 0, "", ""
 }
 
-func (this *GitController) ExecCredGitCommand(cred GGCredentials, mode CredMode, forceNetRCClean bool, nosslverify bool, args ...string) string {
+func (this *GitController) ExecCredGitCommand(cred GGCredentials, mode CredMode, forceNetRCClean bool, nosslverify bool, args ...string) (string, error) {
+
+	env := cred.GitEnv()
 
 	if IsEmpty(cred.Host) || IsEmpty(cred.Username) || IsEmpty(cred.Password) {
-		return this.ExecGitCommand(nosslverify, args...)
+		return this.ExecGitCommandEnv(env, nosslverify, args...)
 	}
 
 	if mode == CredModeNetRC {
 		EnterNetRCBlock(cred.Host, cred.Username, cred.Password)
-		stdout := this.ExecGitCommand(nosslverify, args...)
+		stdout, err := this.ExecGitCommandEnv(env, nosslverify, args...)
 		ExitNetRCBlock(forceNetRCClean)
-		return stdout
+		return stdout, err
 	} else if mode == CredModeHelper {
-		gitargs := []string{"-c", "credential.helper=\"!" + BINARY_PATH + " credentials " + cred.UniqID + "\""}
+		env = append(env, CryptKeyEnv()...) // the credential helper loads the config again
+		gitargs := []string{"-c", "credential.helper=!" + shellQuote(BINARY_PATH) + " credentials " + cred.UniqID}
 		gitargs = append(gitargs, args...)
-		stdout := this.ExecGitCommand(nosslverify, gitargs...)
-		return stdout
+		return this.ExecGitCommandEnv(env, nosslverify, gitargs...)
 	} else if mode == CredModeCFile {
 		tf, cleanup := CreateCredTempFile(cred.Host, cred.Username, cred.Password)
 		defer cleanup()
 		gitargs := []string{"-c", "credential.helper=store --file " + tf}
 		gitargs = append(gitargs, args...)
-		stdout := this.ExecGitCommand(nosslverify, gitargs...)
-		return stdout
+		return this.ExecGitCommandEnv(env, nosslverify, gitargs...)
 	}
 
 	EXIT_ERROR("Invalid CredMode: "+string(mode), EXIT_CONFIG_VALUE_ERROR)
-	return ""
+	return "", nil
 }
 
-func (this *GitController) RemoveRemoteIfExists(name string) {
-	branches := this.ExecGitCommand(false, "remote")
+func (this *GitController) RemoveRemoteIfExists(name string) error {
+	branches, err := this.ExecGitCommand(false, "remote")
+	if err != nil {
+		return err
+	}
 
 	for _, remote := range strings.Split(branches, "\n") {
 		if !IsEmpty(remote) && (remote == name || name == "") {
-			this.ExecGitCommand(false, "remote", "rm", remote)
+			if _, err := this.ExecGitCommand(false, "remote", "rm", remote); err != nil {
+				return err
+			}
 		}
 	}
+
+	return nil
+}
+
+// SetRemote (re-)creates the remote 'name' with the given url
+func (this *GitController) SetRemote(name string, remote string) error {
+	if err := this.RemoveRemoteIfExists(name); err != nil {
+		return err
+	}
+	_, err := this.ExecGitCommand(false, "remote", "add", name, remote)
+	return err
 }
 
-func (this *GitController) CloneOrPull(branch string, remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) {
+func (this *GitController) CloneOrPull(branch string, remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) error {
 
-	if this.ExistsLocal() {
-		this.RemoveRemoteIfExists("origin")
-		this.ExecGitCommand(false, "remote", "add", "origin", remote)
+	exists, err := this.ExistsLocal()
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		if err := this.SetRemote("origin", remote); err != nil {
+			return err
+		}
 
-		this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "fetch", "--all")
+		if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "fetch", "--all"); err != nil {
+			return err
+		}
 
-		this.ExecGitCommand(false, "checkout", "--force", "-B", branch)
-		this.ExecGitCommand(false, "reset", "--hard", "origin/"+branch)
+		if _, err := this.ExecGitCommand(false, "checkout", "--force", "-B", branch); err != nil {
+			return err
+		}
+		if _, err := this.ExecGitCommand(false, "reset", "--hard", "origin/"+branch); err != nil {
+			return err
+		}
 
 	} else {
-		this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "clone", remote, ".", "--origin", "origin")
+		if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "clone", remote, ".", "--origin", "origin"); err != nil {
+			return err
+		}
 
-		this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "fetch", "--all")
+		if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "fetch", "--all"); err != nil {
+			return err
+		}
+
+		if _, err := this.ExecGitCommand(false, "checkout", "--force", "origin/"+branch); err != nil {
+			return err
+		}
+	}
 
-		this.ExecGitCommand(false, "checkout", "--force", "origin/"+branch)
+	if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "branch", "--set-upstream-to=origin/"+branch, branch); err != nil {
+		return err
 	}
+	_, err = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "clean", "--force", "-d")
+	return err
+}
 
-	this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "branch", "--set-upstream-to=origin/"+branch, branch)
-	this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "clean", "--force", "-d")
+func (this *GitController) FetchAltRemote(name string, remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) error {
+	if err := this.RemoveRemoteIfExists(name); err != nil {
+		return err
+	}
+	if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "remote", "add", name, remote); err != nil {
+		return err
+	}
+	_, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "fetch", name, "--prune", "--prune-tags", "--tags")
+	return err
 }
 
-func (this *GitController) FetchAltRemote(name string, remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) {
-	this.RemoveRemoteIfExists(name)
-	this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "remote", "add", name, remote)
-	this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "fetch", name, "--prune", "--prune-tags", "--tags")
+// PruneLocalRefs removes the branches and tags from the local repository that were deleted in origin
+func (this *GitController) PruneLocalRefs(cred GGCredentials, credmode CredMode, forceNetRCClean bool) error {
+	_, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "fetch", "origin", "--prune", "--prune-tags")
+	return err
 }
 
 func (this *GitController) GetHeadHash(originName string, branch string, hashlen int) string {
@@ -176,83 +262,385 @@ func (this *GitController) GetHeadHash(originName string, branch string, hashlen
 	}
 }
 
-func (this *GitController) PushBack(branch string, remote string, cred GGCredentials, credmode CredMode, useForce bool, forceFallback bool, forceNetRCClean bool) {
+// PushBack pushes the local branch to the target, if followTags is set reachable tags are pushed too (Tags == follow)
+// The local branch is pushed as targetBranch (see GGMirror.RefMap)
+func (this *GitController) PushBack(branch string, targetBranch string, remote string, cred GGCredentials, credmode CredMode, useForce bool, forceFallback bool, forceNetRCClean bool, followTags bool, policy DivergencePolicy, backup BackupOptions) error {
+
+	if err := this.SetRemote("origin", remote); err != nil {
+		return err
+	}
 
-	this.RemoveRemoteIfExists("origin")
-	this.ExecGitCommand(false, "remote", "add", "origin", remote)
+	// removing the remote also removed its remote-tracking branches
+	if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "fetch", "origin"); err != nil {
+		return err
+	}
+
+	exists, err := this.HasRemoteBranch(targetBranch, cred.NoSSLVerify)
+	if err != nil {
+		return err
+	}
 
-	if this.HasRemoteBranch(branch, cred.NoSSLVerify) {
-		LOG_OUT("Branch " + branch + " does exist on remote " + remote)
-		this.PushBackExistingBranch(branch, remote, cred, credmode, useForce, forceFallback, forceNetRCClean, cred.NoSSLVerify)
+	if exists {
+		this.Log.Out("Branch " + targetBranch + " does exist on remote " + remote)
+		return this.PushBackExistingBranch(branch, targetBranch, remote, cred, credmode, useForce, forceFallback, forceNetRCClean, cred.NoSSLVerify, followTags, policy, backup)
 	} else {
-		LOG_OUT("Branch " + branch + " does not exist on remote " + remote)
-		this.PushBackNewBranch(branch, remote, cred, credmode, useForce, forceFallback, forceNetRCClean, cred.NoSSLVerify)
+		this.Log.Out("Branch " + targetBranch + " does not exist on remote " + remote)
+		return this.PushBackNewBranch(branch, targetBranch, remote, cred, credmode, useForce, forceFallback, forceNetRCClean, cred.NoSSLVerify, followTags, policy, backup)
 	}
 }
 
-func (this *GitController) PushBackExistingBranch(branch string, remote string, cred GGCredentials, credmode CredMode, useForce bool, forceFallback bool, forceNetRCClean bool, nosslverify bool) {
+// If the normal push fails the force-push of forceFallback is only done if the branch diverged and the policy allows it
+func (this *GitController) PushBackExistingBranch(branch string, targetBranch string, remote string, cred GGCredentials, credmode CredMode, useForce bool, forceFallback bool, forceNetRCClean bool, nosslverify bool, followTags bool, policy DivergencePolicy, backup BackupOptions) error {
 
-	this.RemoveRemoteIfExists("origin")
-	this.ExecGitCommand(false, "remote", "add", "origin", remote)
+	if err := this.SetRemote("origin", remote); err != nil {
+		return err
+	}
+
+	if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, "fetch", "--all"); err != nil {
+		return err
+	}
+	if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "branch", "--set-upstream-to=origin/"+targetBranch, branch); err != nil {
+		return err
+	}
+	if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "checkout", branch); err != nil {
+		return err
+	}
 
-	this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, "fetch", "--all")
-	this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "branch", "--set-upstream-to=origin/"+branch, branch)
-	this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "checkout", branch)
-	status := this.ExecGitCommand(false, "status")
-	LOG_OUT(status)
+	status, err := this.ExecGitCommand(false, "status")
+	if err != nil {
+		return err
+	}
+	this.Log.Out(status)
 
 	var commandoutput string
 
+	args := []string{"push", "origin", "HEAD:" + targetBranch}
+	if followTags {
+		args = append(args, "--follow-tags")
+	}
+
 	if useForce {
-		commandoutput = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, "push", "origin", "HEAD:"+branch, "--follow-tags", "--force")
+		if lost, err := this.CountLostCommits("HEAD", targetBranch); err == nil && lost > 0 {
+			if backup.Mode == BackupModeNone {
+				this.Log.Out("Target contains " + strconv.Itoa(lost) + " commit(s) that are not in the source - force-pushing, they will be lost (Force = true)")
+			} else {
+				this.Log.Out("Target contains " + strconv.Itoa(lost) + " commit(s) that are not in the source - backing them up before force-pushing (Force = true)")
+				if err := this.BackupTargetBranch(targetBranch, remote, cred, credmode, forceNetRCClean, backup); err != nil {
+					return err
+				}
+			}
+		}
+		commandoutput, err = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, append(args, "--force")...)
 	} else if forceFallback {
-		exitcode, stdout, _ := this.ExecCredGitCommandSafe(cred, credmode, forceNetRCClean, nosslverify, "push", "origin", "HEAD:"+branch, "--follow-tags")
+		exitcode, stdout, _ := this.ExecCredGitCommandSafe(cred, credmode, forceNetRCClean, nosslverify, args...)
 		commandoutput = stdout
 		if exitcode != 0 {
-			LOG_OUT("Command in normal mode failed - falling back to force-push")
-			commandoutput = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, "push", "origin", "HEAD:"+branch, "--follow-tags", "--force")
+			if err = this.GuardForceFallback(targetBranch, remote, cred, credmode, forceNetRCClean, policy, backup); err == nil {
+				this.Log.Out("Command in normal mode failed - falling back to force-push")
+				commandoutput, err = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, append(args, "--force")...)
+			}
 		}
 	} else {
-		commandoutput = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, "push", "origin", "HEAD:"+branch, "--follow-tags")
+		commandoutput, err = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, args...)
 	}
 
-	LOG_OUT(commandoutput)
+	this.Log.Out(commandoutput)
+
+	return err
 }
 
-func (this *GitController) PushBackNewBranch(branch string, remote string, cred GGCredentials, credmode CredMode, useForce bool, forceFallback bool, forceNetRCClean bool, nosslverify bool) {
+func (this *GitController) PushBackNewBranch(branch string, targetBranch string, remote string, cred GGCredentials, credmode CredMode, useForce bool, forceFallback bool, forceNetRCClean bool, nosslverify bool, followTags bool, policy DivergencePolicy, backup BackupOptions) error {
+
+	if err := this.SetRemote("origin", remote); err != nil {
+		return err
+	}
 
-	this.RemoveRemoteIfExists("origin")
-	this.ExecGitCommand(false, "remote", "add", "origin", remote)
+	if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, "fetch", "--all"); err != nil {
+		return err
+	}
+	if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "checkout", branch); err != nil {
+		return err
+	}
 
-	this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, "fetch", "--all")
-	this.ExecCredGitCommand(cred, credmode, forceNetRCClean, false, "checkout", branch)
-	status := this.ExecGitCommand(false, "status")
-	LOG_OUT(status)
+	status, err := this.ExecGitCommand(false, "status")
+	if err != nil {
+		return err
+	}
+	this.Log.Out(status)
 
 	var commandoutput string
 
+	args := []string{"push", "origin", "HEAD:" + targetBranch}
+	if followTags {
+		args = append(args, "--tags")
+	}
+
 	if useForce {
-		commandoutput = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, "push", "origin", "HEAD:"+branch, "--tags", "--force")
+		commandoutput, err = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, append(args, "--force")...)
 	} else if forceFallback {
-		exitcode, stdout, _ := this.ExecCredGitCommandSafe(cred, credmode, forceNetRCClean, nosslverify, "push", "origin", "HEAD:"+branch, "--tags")
+		exitcode, stdout, _ := this.ExecCredGitCommandSafe(cred, credmode, forceNetRCClean, nosslverify, args...)
 		commandoutput = stdout
 		if exitcode != 0 {
-			LOG_OUT("Command in normal mode failed - falling back to force-push")
-			commandoutput = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, "push", "origin", "HEAD:"+branch, "--tags", "--force")
+			// the branch did not exist when we fetched, it must have been created in the target since then (GuardForceFallback fetches it again)
+			if err = this.GuardForceFallback(targetBranch, remote, cred, credmode, forceNetRCClean, policy, backup); err == nil {
+				this.Log.Out("Command in normal mode failed - falling back to force-push")
+				commandoutput, err = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, append(args, "--force")...)
+			}
+		}
+	} else {
+		commandoutput, err = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, args...)
+	}
+
+	this.Log.Out(commandoutput)
+
+	return err
+}
+
+// UsesLFS returns true if the .gitattributes at ref configure the LFS filter
+func (this *GitController) UsesLFS(ref string) bool {
+	exitcode, stdout, _, err := this.ExecGitCommandErr(false, "show", ref+":.gitattributes")
+	return err == nil && exitcode == 0 && strings.Contains(stdout, "filter=lfs")
+}
+
+// LFSFetch downloads the LFS objects of all local refs (including remote-tracking branches) from the remote
+func (this *GitController) LFSFetch(remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) error {
+	if err := this.SetRemote("lfs-source", remote); err != nil {
+		return err
+	}
+
+	_, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "lfs", "fetch", "--all", "lfs-source")
+
+	// otherwise the next 'fetch --all' would also fetch from here
+	if rmerr := this.RemoveRemoteIfExists("lfs-source"); err == nil {
+		err = rmerr
+	}
+	return err
+}
+
+// LFSPush uploads the LFS objects referenced by refs (or by all local refs if empty) to the remote
+func (this *GitController) LFSPush(remote string, refs []string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) error {
+	if err := this.SetRemote("lfs-target", remote); err != nil {
+		return err
+	}
+
+	args := append([]string{"lfs", "push", "--all", "lfs-target"}, refs...)
+	_, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, args...)
+
+	if rmerr := this.RemoveRemoteIfExists("lfs-target"); err == nil {
+		err = rmerr
+	}
+	return err
+}
+
+// MirrorForgeRefs are created by the forges themselves (pull/merge requests, pipelines), the target forge rejects pushes to them
+var MirrorForgeRefs = []string{"refs/pull/*", "refs/merge-requests/*", "refs/keep-around/*", "refs/pipelines/*", "refs/environments/*"}
+
+// MirrorFetchRefSpecs returns the refspecs of MirrorFetch: all refs except MirrorForgeRefs
+func MirrorFetchRefSpecs() []string {
+	result := []string{"+refs/*:refs/*"}
+	for _, ref := range MirrorForgeRefs {
+		result = append(result, "^"+ref)
+	}
+	return result
+}
+
+// MirrorPushRefSpecs returns the refspecs of MirrorPush: the configured refspecs or all refs except MirrorForgeRefs,
+// refs in keepNamespace (if set) are excluded so that --prune does not delete them in the target
+func MirrorPushRefSpecs(refspecs []string, keepNamespace string) []string {
+	result := make([]string, 0, len(refspecs)+len(MirrorForgeRefs)+2)
+
+	if len(refspecs) == 0 {
+		result = append(result, "refs/*:refs/*")
+		for _, ref := range MirrorForgeRefs {
+			result = append(result, "^"+ref)
 		}
 	} else {
-		commandoutput = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, nosslverify, "push", "origin", "HEAD:"+branch, "--tags")
+		result = append(result, refspecs...)
+	}
+
+	if keepNamespace != "" {
+		result = append(result, "^"+keepNamespace+"/*")
+	}
+
+	return result
+}
+
+// MirrorFetch updates the bare repository with all refs of the source (except MirrorForgeRefs), refs that were deleted in the source are pruned
+func (this *GitController) MirrorFetch(remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) error {
+
+	exists, err := this.ExistsLocal()
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		if _, err := this.ExecGitCommand(false, "init", "--bare"); err != nil {
+			return err
+		}
+	}
+
+	args := append([]string{"fetch", "--prune", remote}, MirrorFetchRefSpecs()...)
+	_, err = this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, args...)
+	return err
+}
+
+// MirrorPush pushes all refs (or the refspecs, see MirrorPushRefSpecs) to the target and deletes the refs that do not exist locally
+// If the push is rejected for some refs they are force-pushed one by one (useForce or forceFallback), the divergence policy and backup are applied per ref
+func (this *GitController) MirrorPush(remote string, refspecs []string, cred GGCredentials, credmode CredMode, useForce bool, forceFallback bool, forceNetRCClean bool, policy DivergencePolicy, backup BackupOptions) ([]string, error) {
+
+	keepNamespace := ""
+	if backup.KeepsRefs(policy) {
+		keepNamespace = backup.Namespace // the backups only exist in the target, they must not be pruned
+	}
+
+	args := append([]string{"push", "--porcelain", "--prune", remote}, MirrorPushRefSpecs(refspecs, keepNamespace)...)
+
+	if !useForce && !forceFallback {
+		commandoutput, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, args...)
+		this.Log.Out(commandoutput)
+		return ParsePushPorcelain(commandoutput), err
+	}
+
+	exitcode, stdout, stderr := this.ExecCredGitCommandSafe(cred, credmode, forceNetRCClean, cred.NoSSLVerify, args...)
+	this.Log.Out(stdout)
+
+	changed := ParsePushPorcelain(stdout) // a failed push can still have updated some refs
+
+	if exitcode == 0 {
+		return changed, nil
+	}
+
+	rejected := ParsePushRejected(stdout)
+	if len(rejected) == 0 {
+		return changed, errors.New("Error in command 'git push'\n\n" + stderr)
+	}
+
+	forceRefSpecs := make([]string, 0, len(rejected))
+	errs := make([]string, 0)
+
+	for _, refspec := range rejected {
+		if err := this.guardMirrorRef(refspec[0], refspec[1], remote, cred, credmode, useForce, forceNetRCClean, policy, backup); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		forceRefSpecs = append(forceRefSpecs, "+"+refspec[0]+":"+refspec[1])
+	}
+
+	if len(forceRefSpecs) > 0 {
+		this.Log.Out("Command in normal mode failed - force-pushing " + strconv.Itoa(len(forceRefSpecs)) + " rejected ref(s)")
+		commandoutput, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, append([]string{"push", "--porcelain", remote}, forceRefSpecs...)...)
+		this.Log.Out(commandoutput)
+
+		for _, ref := range ParsePushPorcelain(commandoutput) {
+			changed = AppendIfUniqueCaseInsensitive(changed, ref)
+		}
+
+		if err != nil {
+			return changed, err
+		}
+	}
+
+	if len(errs) > 0 {
+		return changed, errors.New(strings.Join(errs, "\n"))
+	}
+
+	return changed, nil
+}
+
+// guardMirrorRef fetches the rejected ref of the target and decides if the local ref may be force-pushed over it (see GuardForcePush)
+// With useForce the ref is always forced, it is only backed up (if ForceBackup is set) if commits of the target would be lost
+func (this *GitController) guardMirrorRef(local string, dst string, remote string, cred GGCredentials, credmode CredMode, useForce bool, forceNetRCClean bool, policy DivergencePolicy, backup BackupOptions) error {
+	trackingRef := "refs/gogitmirror-check/" + strings.TrimPrefix(dst, "refs/") // outside of the pushed refspecs only while it exists
+
+	if _, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "fetch", "--no-tags", remote, "+"+dst+":"+trackingRef); err != nil {
+		return err
 	}
+	defer func() { _, _ = this.ExecGitCommand(false, "update-ref", "-d", trackingRef) }()
 
-	LOG_OUT(commandoutput)
+	name := dst
+	if strings.HasPrefix(dst, "refs/heads/") {
+		name = dst[len("refs/heads/"):]
+	}
+
+	if useForce {
+		lost, err := this.countLostCommitsRef(local, trackingRef, name)
+		if err != nil || lost == 0 {
+			return err
+		}
+		if backup.Mode == BackupModeNone {
+			this.Log.Out("Target contains " + strconv.Itoa(lost) + " commit(s) in " + name + " that are not in the source - force-pushing, they will be lost (Force = true)")
+			return nil
+		}
+		this.Log.Out("Target contains " + strconv.Itoa(lost) + " commit(s) in " + name + " that are not in the source - backing them up before force-pushing (Force = true)")
+		return this.BackupTargetRef(trackingRef, name, remote, cred, credmode, forceNetRCClean, backup)
+	}
+
+	if strings.HasPrefix(dst, "refs/tags/") {
+		if lost, err := this.countLostCommitsRef(local, trackingRef, name); err == nil && lost == 0 {
+			this.Log.Out("Tag " + name + " was moved in the source - force-pushing it (no commits of the target are lost)")
+			return nil
+		}
+	}
+
+	return this.GuardForcePush(local, trackingRef, name, remote, cred, credmode, forceNetRCClean, policy, backup)
 }
 
-func (this *GitController) GarbageCollect() {
-	this.ExecGitCommand(false, "gc")
+// ParsePushRejected returns the refspecs (local ref, remote ref) that were rejected by 'git push --porcelain'
+func ParsePushRejected(stdout string) [][2]string {
+	result := make([][2]string, 0)
+
+	for _, line := range strings.Split(stdout, "\n") {
+		cols := strings.Split(line, "\t")
+		if len(cols) < 3 || cols[0] != "!" {
+			continue
+		}
+
+		idx := strings.Index(cols[1], ":")
+		if idx <= 0 {
+			continue // a rejected deletion
+		}
+
+		result = append(result, [2]string{cols[1][:idx], cols[1][idx+1:]})
+	}
+
+	return result
+}
+
+// ParsePushPorcelain returns the remote refs that were created, updated or deleted by 'git push --porcelain'
+func ParsePushPorcelain(stdout string) []string {
+	result := make([]string, 0)
+
+	for _, line := range strings.Split(stdout, "\n") {
+		cols := strings.Split(line, "\t")
+		if len(cols) < 3 || len(cols[0]) != 1 || !strings.Contains(" +-*", cols[0]) {
+			continue // header ('To ...'), footer ('Done'), up-to-date ('=') or rejected ('!') refs
+		}
+
+		refs := cols[1]
+		if idx := strings.Index(refs, ":"); idx >= 0 {
+			refs = refs[idx+1:]
+		}
+
+		result = append(result, refs)
+	}
+
+	return result
 }
 
-func (this *GitController) ListLocalBranches() []string {
-	stdout := this.ExecGitCommand(false, "branch", "--all", "--list")
+func (this *GitController) GarbageCollect() error {
+	_, err := this.ExecGitCommand(false, "gc")
+	return err
+}
+
+func (this *GitController) ListLocalBranches() ([]string, error) {
+	if this.Bare {
+		return this.ListBareBranches()
+	}
+
+	stdout, err := this.ExecGitCommand(false, "branch", "--all", "--list")
+	if err != nil {
+		return nil, err
+	}
 	lines := strings.Split(stdout, "\n")
 
 	result := make([]string, 0)
@@ -282,11 +670,122 @@ func (this *GitController) ListLocalBranches() []string {
 		}
 	}
 
-	return result
+	return result, nil
+}
+
+// ListRemoteRefs returns the branches and tags (as full refs) of a remote repository
+func (this *GitController) ListRemoteRefs(remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) ([]string, error) {
+	stdout, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "ls-remote", "--heads", "--tags", remote)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0)
+
+	for _, line := range strings.Split(stdout, "\n") {
+		cols := strings.Fields(line)
+		if len(cols) != 2 || strings.HasSuffix(cols[1], "^{}") {
+			continue
+		}
+		result = append(result, cols[1])
+	}
+
+	return result, nil
+}
+
+// ListRemoteTags returns the tags of a remote repository (name -> sha of the tag object)
+func (this *GitController) ListRemoteTags(remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) (map[string]string, error) {
+	stdout, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "ls-remote", "--tags", remote)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+
+	for _, line := range strings.Split(stdout, "\n") {
+		cols := strings.Fields(line)
+		if len(cols) != 2 || !strings.HasPrefix(cols[1], "refs/tags/") || strings.HasSuffix(cols[1], "^{}") {
+			continue
+		}
+		result[cols[1][len("refs/tags/"):]] = cols[0]
+	}
+
+	return result, nil
+}
+
+// ListSubmoduleURLs returns the submodule urls in the .gitmodules of ref (empty if there is no .gitmodules)
+func (this *GitController) ListSubmoduleURLs(ref string) ([]string, error) {
+	exitcode, _, _, err := this.ExecGitCommandErr(false, "cat-file", "-e", ref+":.gitmodules")
+	if err != nil {
+		return nil, err
+	}
+	if exitcode != 0 {
+		return []string{}, nil
+	}
+
+	exitcode, stdout, stderr, err := this.ExecGitCommandErr(false, "config", "--blob", ref+":.gitmodules", "--get-regexp", "^submodule\\..*\\.url$")
+	if err != nil {
+		return nil, err
+	}
+	if exitcode == 1 {
+		return []string{}, nil // no submodule has an url
+	}
+	if exitcode != 0 {
+		return nil, errors.New("Error in command 'git config'\n\n" + stderr)
+	}
+
+	result := make([]string, 0)
+
+	for _, line := range strings.Split(stdout, "\n") {
+		if idx := strings.Index(line, " "); idx >= 0 && !IsEmpty(line[idx+1:]) {
+			result = append(result, strings.TrimSpace(line[idx+1:]))
+		}
+	}
+
+	return result, nil
 }
 
-func (this *GitController) HasRemoteBranch(branchname string, nosslverify bool) bool {
-	stdout := this.ExecGitCommand(nosslverify, "branch", "--remotes", "--list")
+// GetRemoteHead returns the default branch of a remote repository (the branch HEAD points to)
+func (this *GitController) GetRemoteHead(remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) (string, error) {
+	stdout, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "ls-remote", "--symref", remote, "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(stdout, "\n") {
+		cols := strings.Fields(line)
+		if len(cols) == 3 && cols[0] == "ref:" && cols[2] == "HEAD" {
+			return strings.TrimPrefix(cols[1], "refs/heads/"), nil
+		}
+	}
+
+	return "", nil
+}
+
+// ListBareBranches lists the branches of a bare mirror (there are no remote-tracking branches)
+func (this *GitController) ListBareBranches() ([]string, error) {
+	stdout, err := this.ExecGitCommand(false, "for-each-ref", "--format=%(refname)", "refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0)
+
+	for _, line := range strings.Split(stdout, "\n") {
+		branch := strings.TrimPrefix(strings.TrimSpace(line), "refs/heads/")
+		if !IsEmpty(branch) {
+			result = AppendIfUniqueCaseInsensitive(result, branch)
+		}
+	}
+
+	return result, nil
+}
+
+func (this *GitController) HasRemoteBranch(branchname string, nosslverify bool) (bool, error) {
+	stdout, err := this.ExecGitCommand(nosslverify, "branch", "--remotes", "--list")
+	if err != nil {
+		return false, err
+	}
 	lines := strings.Split(stdout, "\n")
 
 	for _, line := range lines {
@@ -311,9 +810,94 @@ func (this *GitController) HasRemoteBranch(branchname string, nosslverify bool)
 		branch = strings.TrimSpace(branch)
 
 		if strings.EqualFold(branch, branchname) {
-			return true
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// GetRefHash returns the commit ref points to (or "" if the ref does not exist)
+func (this *GitController) GetRefHash(ref string) string {
+	exitcode, stdout, _, err := this.ExecGitCommandErr(false, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil || exitcode != 0 {
+		return ""
+	}
+	return strings.TrimSpace(stdout)
+}
+
+// IsAncestor returns true if ancestor is reachable from commit (pushing commit over ancestor is a fast-forward)
+func (this *GitController) IsAncestor(ancestor string, commit string) (bool, error) {
+	exitcode, _, stderr, err := this.ExecGitCommandErr(false, "merge-base", "--is-ancestor", ancestor, commit)
+	if err != nil {
+		return false, errors.New("Error executing command 'git merge-base'\n\n" + err.Error())
+	}
+	if exitcode == 1 {
+		return false, nil
+	}
+	if exitcode != 0 {
+		return false, errors.New("Error in command 'git merge-base'\n\n" + stderr)
+	}
+	return true, nil
+}
+
+// CountDivergence returns the number of commits that are only reachable from left and only reachable from right
+func (this *GitController) CountDivergence(left string, right string) (int, int, error) {
+	stdout, err := this.ExecGitCommand(false, "rev-list", "--left-right", "--count", left+"..."+right)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cols := strings.Fields(stdout)
+	if len(cols) != 2 {
+		return 0, 0, errors.New("Unexpected output of 'git rev-list': " + stdout)
+	}
+
+	ahead, err1 := strconv.Atoi(cols[0])
+	behind, err2 := strconv.Atoi(cols[1])
+	if err1 != nil || err2 != nil {
+		return 0, 0, errors.New("Unexpected output of 'git rev-list': " + stdout)
+	}
+
+	return ahead, behind, nil
+}
+
+// FetchBranches (re-)creates the remote 'name' and fetches its branches without tags into refs/remotes/<name>/*
+func (this *GitController) FetchBranches(name string, remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool) error {
+	if err := this.SetRemote(name, remote); err != nil {
+		return err
+	}
+	_, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, "fetch", "--prune", "--no-tags", name)
+	return err
+}
+
+// ListTrackingBranches lists the remote-tracking branches of the remote 'name'
+func (this *GitController) ListTrackingBranches(name string) ([]string, error) {
+	stdout, err := this.ExecGitCommand(false, "for-each-ref", "--format=%(refname)", "refs/remotes/"+name+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0)
+
+	for _, line := range strings.Split(stdout, "\n") {
+		branch := strings.TrimPrefix(strings.TrimSpace(line), "refs/remotes/"+name+"/")
+		if !IsEmpty(branch) && !strings.EqualFold(branch, "HEAD") {
+			result = AppendIfUniqueCaseInsensitive(result, branch)
 		}
 	}
 
-	return false
+	return result, nil
+}
+
+// PushCommit sets the branch of the remote to commit, without force this only succeeds if it is a fast-forward
+func (this *GitController) PushCommit(commit string, branch string, remote string, cred GGCredentials, credmode CredMode, forceNetRCClean bool, followTags bool) error {
+	args := []string{"push", remote, commit + ":refs/heads/" + branch}
+	if followTags {
+		args = append(args, "--follow-tags")
+	}
+
+	stdout, err := this.ExecCredGitCommand(cred, credmode, forceNetRCClean, cred.NoSSLVerify, args...)
+	this.Log.Out(stdout)
+	return err
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMirrorFetchRefSpecs(t *testing.T) {
+	expected := []string{"+refs/*:refs/*", "^refs/pull/*", "^refs/merge-requests/*", "^refs/keep-around/*", "^refs/pipelines/*", "^refs/environments/*"}
+
+	if refspecs := MirrorFetchRefSpecs(); !reflect.DeepEqual(refspecs, expected) {
+		t.Errorf("unexpected fetch refspecs %v", refspecs)
+	}
+}
+
+func TestMirrorPushRefSpecs(t *testing.T) {
+	tests := []struct {
+		refspecs      []string
+		keepNamespace string
+		expected      []string
+	}{
+		{nil, "", []string{"refs/*:refs/*", "^refs/pull/*", "^refs/merge-requests/*", "^refs/keep-around/*", "^refs/pipelines/*", "^refs/environments/*"}},
+		{nil, "refs/gogitmirror/backup", []string{"refs/*:refs/*", "^refs/pull/*", "^refs/merge-requests/*", "^refs/keep-around/*", "^refs/pipelines/*", "^refs/environments/*", "^refs/gogitmirror/backup/*"}},
+		{[]string{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"}, "", []string{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"}},
+		{[]string{"refs/heads/*:refs/heads/*"}, "refs/backup", []string{"refs/heads/*:refs/heads/*", "^refs/backup/*"}},
+	}
+
+	for _, test := range tests {
+		if refspecs := MirrorPushRefSpecs(test.refspecs, test.keepNamespace); !reflect.DeepEqual(refspecs, test.expected) {
+			t.Errorf("MirrorPushRefSpecs(%v, %q) = %v, expected %v", test.refspecs, test.keepNamespace, refspecs, test.expected)
+		}
+	}
+
+	configured := []string{"refs/heads/*:refs/heads/*"}
+	MirrorPushRefSpecs(configured, "refs/backup")
+	if len(configured) != 1 {
+		t.Errorf("MirrorPushRefSpecs must not modify the configured refspecs")
+	}
+}
@@ -5,4 +5,8 @@ go 1.17
 require (
 	github.com/BurntSushi/toml v0.4.1
 	github.com/willf/pad v0.0.0-20200313202418-172aa767f2a4
+	golang.org/x/crypto v0.11.0
+	golang.org/x/term v0.10.0
 )
+
+require golang.org/x/sys v0.10.0
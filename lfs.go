@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync"
+)
+
+var lfsInstalled bool
+var lfsInstalledOnce sync.Once
+
+// IsLFSInstalled returns true if the git-lfs extension is available (only checked once per run)
+func IsLFSInstalled() bool {
+	lfsInstalledOnce.Do(func() {
+		exitcode, _, _, err := CmdRun("", true, "git", "lfs", "version")
+		lfsInstalled = err == nil && exitcode == 0
+	})
+	return lfsInstalled
+}
+
+// MirrorLFS copies the LFS objects referenced by refs (or by all local refs if empty) from the source to every target (LFS == true)
+// If LFS is not enabled or git-lfs is missing we only warn when the source uses LFS, because the targets would only get the pointer files
+func (this GGMirror) MirrorLFS(config GGMConfig, repo GitController, headRef string, refs []string, targets []GGMirror, results []MirrorResult) {
+	if !this.LFS {
+		if repo.UsesLFS(headRef) {
+			this.Log.Out("WARNING: The source uses Git LFS, but LFS is not enabled for this remote (the target will only contain the pointer files)")
+		}
+		return
+	}
+
+	if !IsLFSInstalled() {
+		if repo.UsesLFS(headRef) {
+			this.Log.Out("WARNING: git-lfs is not installed, the LFS objects of " + this.Source + " are not mirrored (the target will only contain the pointer files)")
+		}
+		return
+	}
+
+	this.Log.Out("Fetching LFS objects from source-remote")
+	if err := repo.LFSFetch(this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC); err != nil {
+		this.Log.Out("Failed to fetch LFS objects\n\n" + err.Error())
+		for i := range results {
+			results[i].AddBranch("(lfs)", ResultFailed, err)
+		}
+		return
+	}
+
+	for i, target := range targets {
+		this.Log.Out("Pushing LFS objects to target-remote " + target.Target)
+		if err := repo.LFSPush(target.Target, refs, target.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC); err != nil {
+			this.Log.Out("Failed to push LFS objects to " + target.Target + "\n\n" + err.Error())
+			results[i].AddBranch("(lfs)", ResultFailed, err)
+		}
+	}
+}
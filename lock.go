@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+type LockMode string
+
+const (
+	LockModeWait LockMode = "wait" // block until the lock is free
+	LockModeSkip LockMode = "skip" // silently do nothing
+	LockModeFail LockMode = "fail" // exit with EXIT_LOCKED
+)
+
+// FileLock is an flock() based lock, it is released automatically if the process dies
+type FileLock struct {
+	Path string
+	file *os.File
+}
+
+// AcquireLock tries to lock the file at path, returns nil if the lock is held by someone else (and mode != wait)
+func AcquireLock(path string, exclusive bool, mode LockMode, log *LogBuffer) *FileLock {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		EXIT_ERROR("Cannot create lock folder '"+filepath.Dir(path)+"'", EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		EXIT_ERROR("Cannot open lock file '"+path+"'\n\n"+err.Error(), EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+
+	ok, err := flockFile(f, exclusive, false)
+	if err != nil {
+		EXIT_ERROR("Cannot lock file '"+path+"'\n\n"+err.Error(), EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+
+	if !ok && mode == LockModeWait {
+		log.Out("Waiting for lock " + path)
+		ok, err = flockFile(f, exclusive, true)
+		if err != nil {
+			EXIT_ERROR("Cannot lock file '"+path+"'\n\n"+err.Error(), EXIT_FILESYSTEM_ACCESS_ERROR)
+		}
+	}
+
+	if !ok {
+		_ = f.Close()
+		return nil
+	}
+
+	return &FileLock{Path: path, file: f}
+}
+
+func (this *FileLock) Release() {
+	if this == nil {
+		return
+	}
+	funlockFile(this.file)
+	_ = this.file.Close()
+}
+
+func (this GGMConfig) GetRunLockPath() string {
+	return filepath.Join(ExpandPath(this.TemporaryPath), TEMPFOLDERNAME, "gogitmirror.lock")
+}
+
+func (this GGMConfig) GetCronLockPath() string {
+	return filepath.Join(ExpandPath(this.TemporaryPath), TEMPFOLDERNAME, "cron.lock")
+}
+
+// AcquireRunLock takes the global lock, it is shared (every command only works on the remotes it holds the per-remote lock of)
+// and only exclusive if the commands cannot run concurrently at all (e.g. CredentialMode == NETRC)
+// If the lock is held by another process we either exit silently (skip), exit with an error (fail) or wait
+func (this GGMConfig) AcquireRunLock(exclusive bool) *FileLock {
+	return this.acquireGlobalLock(this.GetRunLockPath(), exclusive, "Another instance of "+PROGNAME+" is running")
+}
+
+// AcquireCronLock takes the exclusive lock that prevents overlapping cron runs, a concurrent single or status is not affected
+func (this GGMConfig) AcquireCronLock() *FileLock {
+	return this.acquireGlobalLock(this.GetCronLockPath(), true, "Another cron run of "+PROGNAME+" is in progress")
+}
+
+func (this GGMConfig) acquireGlobalLock(path string, exclusive bool, message string) *FileLock {
+	lock := AcquireLock(path, exclusive, this.LockMode, nil)
+	if lock != nil {
+		return lock
+	}
+
+	if this.LockMode == LockModeSkip {
+		os.Exit(EXIT_SUCCESS)
+	}
+
+	EXIT_ERROR("ERROR: "+message+" (lock: "+path+")", EXIT_LOCKED)
+	return nil
+}
+
+func (this GGMirror) GetLockPath() string {
+	return this.GetTargetFolder() + ".lock"
+}
+
+// AcquireLock takes the lock of this remote, returns nil if the remote should be skipped
+func (this GGMirror) AcquireLock(config GGMConfig, exclusive bool) *FileLock {
+	lock := AcquireLock(this.GetLockPath(), exclusive, config.LockMode, this.Log)
+	if lock != nil || config.LockMode == LockModeSkip {
+		return lock
+	}
+
+	EXIT_ERROR("ERROR: The remote "+this.Target+" is locked by another instance of "+PROGNAME+" (lock: "+this.GetLockPath()+")", EXIT_LOCKED)
+	return nil
+}
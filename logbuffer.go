@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"sync"
+)
+
+// logMutex guards stdout, so that buffered logs of parallel jobs are never interleaved
+var logMutex sync.Mutex
+
+var activeLogBuffers = make(map[*LogBuffer]struct{})
+var activeLogBuffersMutex sync.Mutex
+
+// LogBuffer collects the output of a single remote and writes it to stdout in one block
+// A nil *LogBuffer is valid and writes directly to stdout
+type LogBuffer struct {
+	buffer bytes.Buffer
+	mutex  sync.Mutex
+}
+
+func NewLogBuffer() *LogBuffer {
+	log := &LogBuffer{}
+
+	activeLogBuffersMutex.Lock()
+	activeLogBuffers[log] = struct{}{}
+	activeLogBuffersMutex.Unlock()
+
+	return log
+}
+
+func (this *LogBuffer) Out(msg string) {
+	if this == nil {
+		LOG_OUT(msg)
+		return
+	}
+
+	msg = RedactSecrets(msg)
+
+	this.mutex.Lock()
+	this.buffer.WriteString(msg + "\n")
+	this.mutex.Unlock()
+}
+
+func (this *LogBuffer) LineSep() {
+	this.Out("")
+}
+
+func (this *LogBuffer) Flush() {
+	if this == nil {
+		return
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	logMutex.Lock()
+	os.Stdout.Write(this.buffer.Bytes())
+	logMutex.Unlock()
+
+	this.buffer.Reset()
+}
+
+// Close flushes the buffer, it must not be used afterwards
+func (this *LogBuffer) Close() {
+	if this == nil {
+		return
+	}
+
+	this.Flush()
+
+	activeLogBuffersMutex.Lock()
+	delete(activeLogBuffers, this)
+	activeLogBuffersMutex.Unlock()
+}
+
+// flushActiveLogBuffers is called before we exit, so that the logs leading up to an error are not lost
+func flushActiveLogBuffers() {
+	activeLogBuffersMutex.Lock()
+	defer activeLogBuffersMutex.Unlock()
+
+	for log := range activeLogBuffers {
+		log.Flush()
+	}
+}
@@ -4,12 +4,21 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 func main() {
 
+	if IsAskPassCall() {
+		ExecAskPass()
+		return
+	}
+
 	if len(os.Args) < 2 || ParamIsSet("help") {
 		ExecHelp()
 		return
@@ -39,6 +48,11 @@ func main() {
 		return
 	}
 
+	if strings.ToLower(os.Args[1]) == "backups" {
+		ExecBackups()
+		return
+	}
+
 	if strings.ToLower(os.Args[1]) == "crypt" {
 		ExecCrypt()
 		return
@@ -64,15 +78,43 @@ func ExecHelp() {
 	fmt.Println("   add $source $target [--force]")
 	fmt.Println("       add a new source-target pair to the configuration")
 	fmt.Println("")
-	fmt.Println("   cron [--force]")
-	fmt.Println("       update all targets, optionally specify --force to")
-	fmt.Println("       force push all remotes")
+	fmt.Println("   cron [--force] [--verbose] [--jobs N] [--fail-fast] [--prune-dry-run] [--submodule-report]")
+	fmt.Println("       update all targets (including the repositories found")
+	fmt.Println("       via [[AutoMirror]]), optionally specify --force to")
+	fmt.Println("       force push all remotes, --verbose lists the repositories")
+	fmt.Println("       that were excluded by AutoMirror filters, --jobs updates")
+	fmt.Println("       N remotes in parallel (default: Parallelism from config),")
+	fmt.Println("       --fail-fast aborts at the first error instead of continuing")
+	fmt.Println("       with the next remote, --prune-dry-run only prints the refs")
+	fmt.Println("       that would be deleted by PruneTarget, --submodule-report lists")
+	fmt.Println("       the submodules that are not mirrored by any remote")
+	fmt.Println("")
+	fmt.Println("   single $id [--force] [--prune-dry-run]")
+	fmt.Println("       update a single remote (by id, source-url or target-url)")
 	fmt.Println("")
 	fmt.Println("   status")
 	fmt.Println("       show status of all configured remotes")
 	fmt.Println("")
-	fmt.Println("   cyrpt $password")
-	fmt.Println("       encrypt an password for use in config file")
+	fmt.Println("   backups [$id]")
+	fmt.Println("       list the backups made before force-pushes (see ForceBackup)")
+	fmt.Println("       of all remotes or of a single remote")
+	fmt.Println("")
+	fmt.Println("   backups restore $id $backup")
+	fmt.Println("       force-push a backup (ref or bundle, as listed by backups)")
+	fmt.Println("       back to its branch in the target")
+	fmt.Println("")
+	fmt.Println("   cron, single, status and backups accept --lock wait|skip|fail to")
+	fmt.Println("       override the LockMode, if another instance is running")
+	fmt.Println("")
+	fmt.Println("   crypt $password")
+	fmt.Println("       encrypt an password for use in config file, the key is read")
+	fmt.Println("       from $GOGITMIRROR_KEY, the file in $GOGITMIRROR_KEYFILE,")
+	fmt.Println("       ~/.config/gogitmirror.key or entered as passphrase")
+	fmt.Println("")
+	fmt.Println("   crypt --rekey [--new-keyfile $file]")
+	fmt.Println("       re-encrypt all passwords in the config file with a new key")
+	fmt.Println("       ($GOGITMIRROR_NEW_KEY, $GOGITMIRROR_NEW_KEYFILE or a passphrase),")
+	fmt.Println("       this also converts the legacy 'aes:' passwords")
 }
 
 func ExecCron(force bool) {
@@ -82,27 +124,155 @@ func ExecCron(force bool) {
 	LOG_LINESEP()
 	config.LoadFromFile(ExpandPath(CONFIG_PATH))
 
-	for _, conf := range config.Remote {
-		LOG_OUT("Processing remote " + conf.Target)
-		LOG_OUT("   > [Credentials.Source] := " + conf.SourceCredentials.Str())
-		LOG_OUT("   > [Credentials.Target] := " + conf.TargetCredentials.Str())
+	config.WarnLegacySecrets()
 
-		conf.Force = conf.Force || force
+	ApplyLockModeParam(&config)
 
-		if config.AutoCleanTempFolder {
-			LOG_OUT("Testing temp folder for remote " + conf.Target)
-			conf.CleanFolder()
-		}
+	cronLock := config.AcquireCronLock()
+	defer cronLock.Release()
 
-		conf.Update(config)
+	lock := config.AcquireRunLock(config.CredentialMode == CredModeNetRC) // ~/.netrc cannot be shared
+	defer lock.Release()
 
-		if config.AutoCleanTempFolder {
-			LOG_OUT("Cleaning temp folder for remote " + conf.Target)
-			conf.CleanFolder()
+	jobs := config.Parallelism
+	if value, ok := ParamValue("jobs"); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			EXIT_ERROR("ERROR: The parameter --jobs needs a positive number", EXIT_ERRONEOUS_CRON_ARGS)
 		}
+		jobs = n
+	}
+
+	if jobs > 1 && config.CredentialMode == CredModeNetRC {
+		EXIT_ERROR("ERROR: The CredentialMode "+string(CredModeNetRC)+" cannot be used with parallel jobs (all jobs would share ~/.netrc)", EXIT_CONFIG_VALUE_ERROR)
+	}
+
+	createdTargets := config.ExpandAutoMirrors(ParamIsSet("verbose"))
 
+	if ParamIsSet("fail-fast") {
+		config.FailFast = true
+	}
+
+	if ParamIsSet("prune-dry-run") {
+		config.PruneDryRun = true
+	}
+
+	results := make([][]MirrorResult, 0, len(config.Remote))
+
+	// mirrors for newly found submodules are processed in an additional pass (until no new submodules are found)
+	remotes := config.Remote
+	for len(remotes) > 0 {
+		batch := ProcessRemotes(config, remotes, jobs, force)
+		results = append(results, batch...)
+		remotes = config.AddSubmoduleMirrors(remotes, batch)
+	}
+
+	if len(createdTargets) > 0 {
+		LOG_OUT("Created " + strconv.Itoa(len(createdTargets)) + " new repositories on AutoMirror targets:")
+		for _, target := range createdTargets {
+			LOG_OUT("   > " + target)
+		}
 		LOG_LINESEP()
 	}
+
+	summary := make([]MirrorResult, 0, len(results))
+	for _, remoteResults := range results {
+		summary = append(summary, remoteResults...)
+	}
+
+	if config.SubmoduleReport || ParamIsSet("submodule-report") {
+		config.OutputSubmoduleReport(summary)
+	}
+
+	if OutputSummary(summary) {
+		EXIT_ERROR("ERROR: At least one remote failed", EXIT_REMOTES_FAILED)
+	}
+}
+
+// ProcessRemotes updates the remotes with the given number of parallel jobs, returns the results in the order of the remotes
+func ProcessRemotes(config GGMConfig, remotes []GGMirror, jobs int, force bool) [][]MirrorResult {
+	results := make([][]MirrorResult, len(remotes))
+
+	if jobs == 1 {
+		for i, conf := range remotes {
+			results[i] = ProcessRemote(config, conf, force)
+		}
+		return results
+	}
+
+	LOG_OUT("Processing " + strconv.Itoa(len(remotes)) + " remotes with " + strconv.Itoa(jobs) + " parallel jobs")
+	LOG_LINESEP()
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range queue {
+				conf := remotes[idx]
+				conf.Log = NewLogBuffer()
+				results[idx] = ProcessRemote(config, conf, force)
+				conf.Log.Close()
+			}
+		}()
+	}
+
+	for i := range remotes {
+		queue <- i
+	}
+	close(queue)
+
+	wg.Wait()
+
+	return results
+}
+
+// ProcessRemote updates a single remote (returns one result per target), all output is written to conf.Log
+// If config.FailFast is set the program is terminated on the first error
+func ProcessRemote(config GGMConfig, conf GGMirror, force bool) []MirrorResult {
+	conf.Log.Out("Processing remote " + conf.Target)
+	conf.Log.Out("   > [Credentials.Source] := " + conf.SourceCredentials.Str())
+	for _, target := range conf.TargetViews() {
+		conf.Log.Out("   > [Credentials.Target] := " + target.TargetCredentials.Str() + " (" + target.Target + ")")
+	}
+
+	conf.Force = conf.Force || force
+
+	lock := conf.AcquireLock(config, true)
+	if lock == nil {
+		conf.Log.Out("Skip remote " + conf.Target + " (locked by another instance)")
+		conf.Log.LineSep()
+		results := conf.NewResults(conf.TargetViews())
+		for i := range results {
+			results[i].AddBranch("-", ResultSkipped, nil)
+		}
+		return results
+	}
+	defer lock.Release()
+
+	if config.AutoCleanTempFolder {
+		conf.Log.Out("Testing temp folder for remote " + conf.Target)
+		conf.CleanFolder()
+	}
+
+	results := conf.Update(config)
+
+	for _, result := range results {
+		if result.Failed() && config.FailFast {
+			EXIT_ERROR(result.FirstError().Error(), EXIT_GIT_ERROR)
+		}
+	}
+
+	if config.AutoCleanTempFolder {
+		conf.Log.Out("Cleaning temp folder for remote " + conf.Target)
+		conf.CleanFolder()
+	}
+
+	conf.Log.LineSep()
+
+	return results
 }
 
 func ExecSingle(force bool) {
@@ -118,30 +288,23 @@ func ExecSingle(force bool) {
 	LOG_LINESEP()
 	config.LoadFromFile(ExpandPath(CONFIG_PATH))
 
-	for _, conf := range config.Remote {
-
-		if search != "" && (strings.ToLower(conf.ID) == search || strings.ToLower(conf.Source) == search || strings.ToLower(conf.Target) == search) {
+	config.WarnLegacySecrets()
 
-			LOG_OUT("Processing remote " + conf.Target)
-			LOG_OUT("   > [Credentials.Source] := " + conf.SourceCredentials.Str())
-			LOG_OUT("   > [Credentials.Target] := " + conf.TargetCredentials.Str())
+	ApplyLockModeParam(&config)
 
-			conf.Force = conf.Force || force
+	if ParamIsSet("prune-dry-run") {
+		config.PruneDryRun = true
+	}
 
-			if config.AutoCleanTempFolder {
-				LOG_OUT("Testing temp folder for remote " + conf.Target)
-				conf.CleanFolder()
-			}
+	lock := config.AcquireRunLock(config.CredentialMode == CredModeNetRC) // ~/.netrc cannot be shared
+	defer lock.Release()
 
-			conf.Update(config)
+	for _, conf := range config.Remote {
 
-			if config.AutoCleanTempFolder {
-				LOG_OUT("Cleaning temp folder for remote " + conf.Target)
-				conf.CleanFolder()
+		if conf.Matches(search) {
+			if OutputSummary(ProcessRemote(config, conf, force)) {
+				EXIT_ERROR("ERROR: The remote failed", EXIT_REMOTES_FAILED)
 			}
-
-			LOG_LINESEP()
-
 			return
 		}
 	}
@@ -186,11 +349,86 @@ func ExecAdd() {
 }
 
 func ExecCrypt() {
+	if ParamIsSet("rekey") {
+		ExecRekey()
+		return
+	}
+
 	if len(os.Args) < 3 {
 		EXIT_ERROR("ERROR: The comand [Credentials] needs an password supplied as argument", EXIT_ERRONEOUS_CRYPT_ARGS)
 	}
 
-	LOG_OUT("aes:" + Encrypt(os.Args[2]))
+	key, err := GetCryptKey()
+	if err != nil {
+		EXIT_ERROR("ERROR: "+err.Error(), EXIT_CRYPT_ERROR)
+	}
+
+	value, err := EncryptSecret(key, os.Args[2])
+	if err != nil {
+		EXIT_ERROR("ERROR: Cannot encrypt the password\n\n"+err.Error(), EXIT_CRYPT_ERROR)
+	}
+
+	LOG_OUT(value)
+}
+
+// ExecRekey re-encrypts all secrets of the config with a new key (legacy 'aes:' values are converted too)
+func ExecRekey() {
+	path := ExpandPath(CONFIG_PATH)
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		EXIT_ERROR("ERROR: Cannot load config from "+path+"\n\n"+err.Error(), EXIT_CONFIG_READ_ERROR)
+	}
+
+	newKeyfile := os.Getenv(ENV_CRYPT_NEWKEYFILE)
+	if value, ok := ParamValue("new-keyfile"); ok {
+		if value == "" {
+			EXIT_ERROR("ERROR: The parameter --new-keyfile needs a path", EXIT_ERRONEOUS_CRYPT_ARGS)
+		}
+		newKeyfile = value
+	}
+
+	newKey := os.Getenv(ENV_CRYPT_NEWKEY)
+	if newKey == "" && newKeyfile == "" {
+		newKey, err = ReadPassphrase("New passphrase: ")
+		if err == nil {
+			if confirm, err2 := ReadPassphrase("Repeat new passphrase: "); err2 != nil || confirm != newKey {
+				EXIT_ERROR("ERROR: The passphrases do not match", EXIT_CRYPT_ERROR)
+			}
+		}
+	} else {
+		newKey, err = ReadCryptKey(newKey, newKeyfile, "", "")
+	}
+	if err != nil {
+		EXIT_ERROR("ERROR: Cannot read the new key (set "+ENV_CRYPT_NEWKEY+", "+ENV_CRYPT_NEWKEYFILE+" or --new-keyfile)\n\n"+err.Error(), EXIT_CRYPT_ERROR)
+	}
+
+	text, count, err := RekeyConfig(string(content), GetCryptKey, newKey)
+	if err != nil {
+		EXIT_ERROR("ERROR: "+err.Error(), EXIT_CRYPT_ERROR)
+	}
+
+	if count == 0 {
+		LOG_OUT("No encrypted values found in " + CONFIG_PATH)
+		return
+	}
+
+	// no backup of the old config is kept, it would still contain the old values (legacy 'aes:' values can be decrypted by anybody)
+	// instead the new config is written to a temporary file first and then moved over the old one
+	if err := ioutil.WriteFile(path+".tmp", []byte(text), 0600); err != nil {
+		EXIT_ERROR("ERROR: Could not write to file '"+CONFIG_PATH+".tmp'", EXIT_CONFIG_WRITE)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		_ = os.Remove(path + ".tmp")
+		EXIT_ERROR("ERROR: Could not write to file '"+CONFIG_PATH+"'\n\n"+err.Error(), EXIT_CONFIG_WRITE)
+	}
+
+	LOG_OUT("Re-encrypted " + strconv.Itoa(count) + " values in " + CONFIG_PATH)
+	if PathExists(path + ".bak") {
+		LOG_OUT("WARNING: " + CONFIG_PATH + ".bak still contains the old values, delete it")
+	}
+	LOG_OUT("From now on supply the new key via " + ENV_CRYPT_KEY + ", " + ENV_CRYPT_KEYFILE + " or " + KEYFILE_PATH)
 }
 
 func ExecStatus(force bool) {
@@ -199,15 +437,182 @@ func ExecStatus(force bool) {
 	LOG_LINESEP()
 	config.LoadFromFile(ExpandPath(CONFIG_PATH))
 
+	ApplyLockModeParam(&config)
+
+	lock := config.AcquireRunLock(config.CredentialMode == CredModeNetRC) // ~/.netrc cannot be shared
+	defer lock.Release()
+
 	LOG_OUT(" | " + forceStrLen("NAME", STAT_COL_NAME) + "| " + forceStrLen("BRANCH", STAT_COL_BRANCH) + "| " + forceStrLen("SOURCE", STAT_COL_SOURCE) + " | " + forceStrLen("LOCAL", STAT_COL_LOCAL) + " | " + forceStrLen("TARGET", STAT_COL_TARGET) + "")
 	LOG_OUT("-|-" + strings.Repeat("-", STAT_COL_NAME) + "|-" + strings.Repeat("-", STAT_COL_BRANCH) + "|-" + strings.Repeat("-", STAT_COL_SOURCE) + "-|-" + strings.Repeat("-", STAT_COL_LOCAL) + "-|-" + strings.Repeat("-", STAT_COL_TARGET) + "-")
 
 	for _, conf := range config.Remote {
 		conf.Force = conf.Force || force
-		conf.OutputStatus(config)
+
+		remoteLock := conf.AcquireLock(config, false)
+		if remoteLock == nil {
+			LOG_OUT(" | " + forceStrLen(conf.GetShortName(), STAT_COL_NAME) + "| " + forceStrLen("LOCKED", STAT_COL_BRANCH) + "|")
+			continue
+		}
+
+		for _, target := range conf.TargetViews() {
+			target.OutputStatus(config)
+		}
+
+		remoteLock.Release()
+	}
+}
+
+func ExecBackups() {
+	var config GGMConfig
+
+	LOG_LINESEP()
+	config.LoadFromFile(ExpandPath(CONFIG_PATH))
+
+	ApplyLockModeParam(&config)
+
+	if len(os.Args) >= 3 && strings.ToLower(os.Args[2]) == "restore" {
+		ExecRestoreBackup(config)
+		return
+	}
+
+	search := ""
+	if len(os.Args) >= 3 && !strings.HasPrefix(os.Args[2], "--") {
+		search = strings.ToLower(strings.TrimSpace(os.Args[2]))
+	}
+
+	lock := config.AcquireRunLock(config.CredentialMode == CredModeNetRC) // ~/.netrc cannot be shared
+	defer lock.Release()
+
+	LOG_OUT(" | " + forceStrLen("NAME", STAT_COL_NAME) + "| " + forceStrLen("BRANCH", STAT_COL_BRANCH) + "| " + forceStrLen("DATE", STAT_COL_DATE) + " | " + forceStrLen("SHA", STAT_COL_TARGET) + " | BACKUP")
+	LOG_OUT("-|-" + strings.Repeat("-", STAT_COL_NAME) + "|-" + strings.Repeat("-", STAT_COL_BRANCH) + "|-" + strings.Repeat("-", STAT_COL_DATE) + "-|-" + strings.Repeat("-", STAT_COL_TARGET) + "-|-" + strings.Repeat("-", STAT_COL_BRANCH))
+
+	for _, conf := range config.Remote {
+		if search != "" && !conf.Matches(search) {
+			continue
+		}
+
+		for _, target := range conf.TargetViews() {
+			repo := backupsGitController(config)
+
+			backups, err := target.ListBackups(config, repo)
+			if err != nil {
+				LOG_OUT("X| " + forceStrLen(target.GetShortName(), STAT_COL_NAME) + "| " + forceStrLen("ERROR", STAT_COL_BRANCH) + "|")
+				continue
+			}
+
+			for _, backup := range backups {
+				sha := backup.Sha
+				if len(sha) > STAT_COL_TARGET {
+					sha = sha[:STAT_COL_TARGET]
+				}
+				LOG_OUT(" | " + forceStrLen(target.GetShortName(), STAT_COL_NAME) + "| " + forceStrLen(backup.Branch, STAT_COL_BRANCH) + "| " + forceStrLen(backup.Time.Local().Format("2006-01-02 15:04:05"), STAT_COL_DATE) + " | " + forceStrLen(sha, STAT_COL_TARGET) + " | " + backup.ID)
+			}
+		}
+	}
+}
+
+// backupsGitController returns the controller for the ls-remote of the backup commands (they need no repository),
+// TemporaryPath is created first because git cannot run in a folder that does not exist
+func backupsGitController(config GGMConfig) GitController {
+	folder := ExpandPath(config.TemporaryPath)
+
+	if !PathIsValid(folder) {
+		EXIT_ERROR("The temporary ggm path is not a valid path '"+folder+"'", EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+
+	if err := os.MkdirAll(folder, 0777); err != nil {
+		EXIT_ERROR("Cannot create tmp folder '"+folder+"'", EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+
+	repo := GitController{Folder: folder}
+	repo.SetSilent()
+	return repo
+}
+
+func ExecRestoreBackup(config GGMConfig) {
+	if len(os.Args) < 5 {
+		EXIT_ERROR("ERROR: The comand [backups restore] needs a remote-id and a backup as parameters", EXIT_ERRONEOUS_BACKUP_ARGS)
+	}
+
+	search := strings.ToLower(strings.TrimSpace(os.Args[3]))
+	id := strings.TrimSpace(os.Args[4])
+
+	lock := config.AcquireRunLock(config.CredentialMode == CredModeNetRC) // ~/.netrc cannot be shared
+	defer lock.Release()
+
+	for _, conf := range config.Remote {
+		if !conf.Matches(search) {
+			continue
+		}
+
+		remoteLock := conf.AcquireLock(config, true)
+		if remoteLock == nil {
+			LOG_OUT("Skip remote " + conf.Target + " (locked by another instance)")
+			return
+		}
+		defer remoteLock.Release()
+
+		for _, target := range conf.TargetViews() {
+			repo := backupsGitController(config)
+
+			backups, err := target.ListBackups(config, repo)
+			if err != nil {
+				EXIT_ERROR("ERROR: Cannot list the backups of "+target.Target+"\n\n"+err.Error(), EXIT_GIT_ERROR)
+			}
+
+			for _, backup := range backups {
+				if backup.ID != id && filepath.Base(backup.ID) != id {
+					continue
+				}
+
+				LOG_OUT("Restoring backup " + backup.ID + " to " + target.Target)
+				if err := target.RestoreBackup(config, backup); err != nil {
+					EXIT_ERROR("ERROR: Failed to restore the backup "+backup.ID+"\n\n"+err.Error(), EXIT_GIT_ERROR)
+				}
+				return
+			}
+		}
+
+		EXIT_ERROR("ERROR: The backup '"+id+"' was not found for the remote "+conf.Target, EXIT_ERRONEOUS_BACKUP_ARGS)
+	}
+
+	EXIT_ERROR("ERROR: No matching remote found, supply source-url, target-url or remote-id", EXIT_ERRONEOUS_SINGLE_ID)
+}
+
+// ApplyLockModeParam overrides the LockMode of the config with the --lock parameter
+func ApplyLockModeParam(config *GGMConfig) {
+	if value, ok := ParamValue("lock"); ok {
+		config.LockMode = LockMode(strings.ToLower(value))
+		if config.LockMode != LockModeWait && config.LockMode != LockModeSkip && config.LockMode != LockModeFail {
+			EXIT_ERROR("ERROR: The parameter --lock must be one of [wait, skip, fail]", EXIT_ERRONEOUS_LOCK_ARGS)
+		}
 	}
 }
 
+// ExecAskPass prints the passphrase of the ssh key when ssh calls this binary as SSH_ASKPASS (see GGCredentials.SSHEnv)
+func ExecAskPass() {
+	uniqid := os.Getenv(ENV_ASKPASS_CRED)
+
+	if !strings.Contains(strings.ToLower(os.Args[1]), "passphrase") {
+		os.Exit(1) // not a passphrase prompt (e.g. a host key confirmation), ssh treats this as cancel
+	}
+
+	var config GGMConfig
+
+	// stdout is read by ssh, so warnings of the config must not end up there
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	cred, found := config.LoadCredentialFromFile(ExpandPath(CONFIG_PATH), uniqid)
+	os.Stdout = stdout
+
+	if found {
+		fmt.Println(cred.SSHKeyPassphrase)
+		return
+	}
+
+	EXIT_ERROR("Credential '"+uniqid+"' not found", EXIT_ERRONEOUS_CRED_ARGS)
+}
+
 func ExecCredHelper() {
 	if len(os.Args) < 3 {
 		EXIT_ERROR("ERROR: The comand [Crypt] needs an cred_index", EXIT_ERRONEOUS_CRYPT_ARGS)
@@ -217,7 +622,11 @@ func ExecCredHelper() {
 
 	var config GGMConfig
 
-	config.LoadFromFile(ExpandPath(CONFIG_PATH))
+	// stdout is read by git, so warnings of the config must not end up there
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	cred, found := config.LoadCredentialFromFile(ExpandPath(CONFIG_PATH), uniqid)
+	os.Stdout = stdout
 
 	stdin := ""
 	scanner := bufio.NewScanner(os.Stdin)
@@ -229,11 +638,9 @@ func ExecCredHelper() {
 		}
 	}
 
-	for _, cred := range config.Credentials {
-		if cred.UniqID == uniqid {
-			fmt.Print("username=" + cred.Username + "\n" + "password=" + cred.Password + "\n\n")
-			return
-		}
+	if found {
+		fmt.Print("username=" + cred.Username + "\n" + "password=" + cred.Password + "\n\n")
+		return
 	}
 
 	EXIT_ERROR("Credential '"+uniqid+"' not found", EXIT_ERRONEOUS_CRED_ARGS)
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Patterns are either globs (e.g. 'release/*') or regular expressions prefixed with 'regex:' (e.g. 'regex:^v[0-9]+$')
+// In globs '*' matches any text (including '/', so 'dependabot/*' also matches 'dependabot/npm/x'), '?' a single character and '[a-z]' (or '[!a-z]') a character class
+
+const regexPatternPrefix = "regex:"
+
+func ValidatePattern(pattern string) error {
+	if strings.HasPrefix(pattern, regexPatternPrefix) {
+		_, err := regexp.Compile(pattern[len(regexPatternPrefix):])
+		return err
+	}
+
+	_, err := globToRegex(pattern)
+	return err
+}
+
+func MatchPattern(pattern string, value string) bool {
+	if strings.HasPrefix(pattern, regexPatternPrefix) {
+		rex, err := regexp.Compile(pattern[len(regexPatternPrefix):])
+		if err != nil {
+			return false
+		}
+		return rex.MatchString(value)
+	}
+
+	rex, err := globToRegex(pattern)
+	return err == nil && rex.MatchString(value)
+}
+
+// MapPattern rewrites value with the replacement if it matches the pattern
+// For globs every '*' in the replacement is substituted with the text matched by the corresponding '*' in the pattern,
+// for regular expressions the replacement can reference the capture groups (e.g. '$1' or '${name}')
+func MapPattern(pattern string, replacement string, value string) (string, bool) {
+	if strings.HasPrefix(pattern, regexPatternPrefix) {
+		rex, err := regexp.Compile(pattern[len(regexPatternPrefix):])
+		if err != nil {
+			return "", false
+		}
+		match := rex.FindStringSubmatchIndex(value)
+		if match == nil {
+			return "", false
+		}
+		return string(rex.ExpandString(nil, replacement, value, match)), true
+	}
+
+	rex, err := globToRegex(pattern)
+	if err != nil {
+		return "", false
+	}
+
+	groups := rex.FindStringSubmatch(value)
+	if groups == nil {
+		return "", false
+	}
+
+	var buffer bytes.Buffer
+	capture := 1
+	for _, chr := range replacement {
+		if chr == '*' && capture < len(groups) {
+			buffer.WriteString(groups[capture])
+			capture++
+		} else {
+			buffer.WriteRune(chr)
+		}
+	}
+	return buffer.String(), true
+}
+
+// globToRegex converts a glob with '*', '?' and '[...]' wildcards to an anchored regex with one capture group per '*'
+func globToRegex(pattern string) (*regexp.Regexp, error) {
+	var buffer bytes.Buffer
+
+	chars := []rune(pattern)
+
+	buffer.WriteString("^")
+	for i := 0; i < len(chars); i++ {
+		if chars[i] == '*' {
+			buffer.WriteString("(.*)")
+		} else if chars[i] == '?' {
+			buffer.WriteString(".")
+		} else if chars[i] == '[' {
+			end := i + 1
+			if end < len(chars) && (chars[end] == '!' || chars[end] == '^') {
+				end++
+			}
+			if end < len(chars) && chars[end] == ']' {
+				end++ // a leading ']' is part of the class
+			}
+			for end < len(chars) && chars[end] != ']' {
+				end++
+			}
+			if end >= len(chars) {
+				return nil, errors.New("Unterminated character class in glob '" + pattern + "'")
+			}
+
+			class := chars[i+1 : end]
+			buffer.WriteString("[")
+			if len(class) > 0 && (class[0] == '!' || class[0] == '^') {
+				buffer.WriteString("^")
+				class = class[1:]
+			}
+			buffer.WriteString(strings.Replace(strings.Replace(string(class), "\\", "\\\\", -1), "[", "\\[", -1))
+			buffer.WriteString("]")
+
+			i = end
+		} else {
+			buffer.WriteString(regexp.QuoteMeta(string(chars[i])))
+		}
+	}
+	buffer.WriteString("$")
+
+	return regexp.Compile(buffer.String())
+}
+
+// MatchAnyPattern returns the first pattern that matches value (or "" if none match)
+func MatchAnyPattern(patterns []string, value string) (string, bool) {
+	for _, pattern := range patterns {
+		if MatchPattern(pattern, value) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
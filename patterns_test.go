@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestGlobToRegex(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{"master", "master", true},
+		{"master", "master2", false},
+		{"release/*", "release/1.0", true},
+		{"release/*", "release/1.0/hotfix", true},
+		{"release/*", "release", false},
+		{"dependabot/*", "dependabot/npm/x", true},
+		{"v?.?", "v1.2", true},
+		{"v?.?", "v1.20", false},
+		{"feature.x", "featureXx", false},
+		{"[a-c]*", "bugfix", true},
+		{"[a-c]*", "develop", false},
+		{"[!a-c]*", "develop", true},
+		{"[]]x", "]x", true},
+	}
+
+	for _, test := range tests {
+		rex, err := globToRegex(test.pattern)
+		if err != nil {
+			t.Errorf("globToRegex(%q) failed: %v", test.pattern, err)
+			continue
+		}
+		if rex.MatchString(test.value) != test.match {
+			t.Errorf("globToRegex(%q).MatchString(%q) != %v", test.pattern, test.value, test.match)
+		}
+	}
+
+	if _, err := globToRegex("release/[abc"); err == nil {
+		t.Errorf("expected an error for an unterminated character class")
+	}
+}
+
+func TestMapPattern(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		replacement string
+		value       string
+		result      string
+		match       bool
+	}{
+		{"*", "mirror/*", "main", "mirror/main", true},
+		{"*", "mirror/*", "feature/x", "mirror/feature/x", true},
+		{"master", "upstream/master", "master", "upstream/master", true},
+		{"master", "upstream/master", "main", "", false},
+		{"feature-*-*", "feature/*/*", "feature-abc-12", "feature/abc/12", true},
+		{"release-*", "release/*/stable", "release-1.0", "release/1.0/stable", true},
+		{"regex:^feature-(.*)$", "feature/$1", "feature-login", "feature/login", true},
+		{"regex:^(?P<major>[0-9]+)\\.x$", "v${major}", "2.x", "v2", true},
+		{"regex:^feature-(.*)$", "feature/$1", "bugfix-login", "", false},
+	}
+
+	for _, test := range tests {
+		result, ok := MapPattern(test.pattern, test.replacement, test.value)
+		if ok != test.match || result != test.result {
+			t.Errorf("MapPattern(%q, %q, %q) = (%q, %v), expected (%q, %v)", test.pattern, test.replacement, test.value, result, ok, test.result, test.match)
+		}
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	if !MatchPattern("regex:^renovate/", "renovate/lodash") {
+		t.Errorf("expected the regex to match")
+	}
+	if MatchPattern("regex:[", "x") {
+		t.Errorf("an invalid regex must not match")
+	}
+	if err := ValidatePattern("regex:["); err == nil {
+		t.Errorf("expected an error for an invalid regex")
+	}
+
+	if pattern, ok := MatchAnyPattern([]string{"main", "release/*", "*"}, "release/1.0"); !ok || pattern != "release/*" {
+		t.Errorf("expected the first matching pattern 'release/*', got %q", pattern)
+	}
+}
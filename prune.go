@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GetPushedRefsPath returns the file that records the refs pushed to the target by gogitmirror (lives next to the temp folder, so that it survives AutoCleanTempFolder)
+func (this GGMirror) GetPushedRefsPath() string {
+	return filepath.Join(ExpandPath(this.TempBaseFolder), TEMPFOLDERNAME, GetFolderName(this.Target)+".pushed")
+}
+
+// LoadPushedRefs returns the refs that were previously pushed to the target (empty if the file does not exist)
+func (this GGMirror) LoadPushedRefs() []string {
+	content, err := ioutil.ReadFile(this.GetPushedRefsPath())
+	if err != nil {
+		return []string{}
+	}
+
+	result := make([]string, 0)
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func (this GGMirror) SavePushedRefs(refs []string) {
+	sort.Strings(refs)
+
+	err := ioutil.WriteFile(this.GetPushedRefsPath(), []byte(strings.Join(refs, "\n")+"\n"), 0600)
+	if err != nil {
+		EXIT_ERROR("Cannot write file '"+this.GetPushedRefsPath()+"'\n\n"+err.Error(), EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+}
+
+// RecordPushedRefs adds refs to the list of refs pushed by gogitmirror
+func (this GGMirror) RecordPushedRefs(refs []string) {
+	if len(refs) == 0 {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(this.GetPushedRefsPath()), 0777); err != nil {
+		EXIT_ERROR("Cannot create tmp folder '"+filepath.Dir(this.GetPushedRefsPath())+"'", EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+
+	pushed := this.LoadPushedRefs()
+	for _, ref := range refs {
+		if !Contains(pushed, ref) {
+			pushed = append(pushed, ref)
+		}
+	}
+	this.SavePushedRefs(pushed)
+}
+
+// IsPruneCandidate returns true if the target ref may be deleted because it does not exist in the source
+func (this GGMirror) IsPruneCandidate(ref string, pushed []string) bool {
+	if this.PruneOnlyMirrored && !Contains(pushed, ref) {
+		return false
+	}
+
+	if strings.HasPrefix(ref, "refs/heads/") && len(this.RefMap) > 0 {
+		return Contains(pushed, ref) // the source name of a mapped branch is unknown, so we only delete the branches we pushed
+	}
+
+	if strings.HasPrefix(ref, "refs/heads/") {
+		branch := ref[len("refs/heads/"):]
+
+		if !this.AutoBranchDiscovery && !ContainsCaseInsensitive(this.Branches, branch) {
+			return false // we only manage the configured branches
+		}
+
+		if this.AutoBranchDiscovery && !this.IsBranchIncluded(branch) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PruneTargetRefs deletes the branches and tags from the target that no longer exist in the source (PruneTarget)
+// Deletions are only logged if config.PruneDryRun is set and nothing is deleted if there are more than PruneLimit candidates
+func (this GGMirror) PruneTargetRefs(config GGMConfig, repo GitController, result *MirrorResult) {
+	sourceRefs, err := repo.ListRemoteRefs(this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
+	if err != nil {
+		result.AddBranch("(prune)", ResultFailed, err)
+		return
+	}
+
+	targetRefs, err := repo.ListRemoteRefs(this.Target, this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
+	if err != nil {
+		result.AddBranch("(prune)", ResultFailed, err)
+		return
+	}
+
+	for i, ref := range sourceRefs {
+		if strings.HasPrefix(ref, "refs/heads/") {
+			sourceRefs[i] = "refs/heads/" + this.MapBranch(ref[len("refs/heads/"):])
+		}
+	}
+
+	pushed := this.LoadPushedRefs()
+
+	mirroredTags := make([]string, 0)
+	candidates := make([]string, 0)
+
+	for _, ref := range targetRefs {
+		if Contains(sourceRefs, ref) {
+			if strings.HasPrefix(ref, "refs/tags/") {
+				mirroredTags = append(mirroredTags, ref)
+			}
+			continue
+		}
+
+		if this.IsPruneCandidate(ref, pushed) {
+			candidates = append(candidates, ref)
+		}
+	}
+
+	this.RecordPushedRefs(mirroredTags)
+
+	if len(candidates) == 0 {
+		this.Log.Out("No refs to prune on target-remote")
+		return
+	}
+
+	overLimit := *this.PruneLimit >= 0 && len(candidates) > *this.PruneLimit
+
+	if config.PruneDryRun {
+		for _, ref := range candidates {
+			this.Log.Out("Would delete " + ref + " from target-remote (dry-run)")
+			result.AddBranch(ref, ResultWouldDelete, nil)
+		}
+		if overLimit {
+			this.Log.Out("A real run would delete nothing, these are more than PruneLimit = " + strconv.Itoa(*this.PruneLimit) + " refs")
+		}
+		return
+	}
+
+	if overLimit {
+		err := errors.New("Refusing to delete " + strconv.Itoa(len(candidates)) + " refs from the target (PruneLimit = " + strconv.Itoa(*this.PruneLimit) + "): " + strings.Join(candidates, ", "))
+		this.Log.Out(err.Error())
+		result.AddBranch("(prune)", ResultFailed, err)
+		return
+	}
+
+	this.Log.Out("Deleting " + strconv.Itoa(len(candidates)) + " refs from target-remote")
+
+	args := append([]string{"push", "--delete", this.Target}, candidates...)
+	if _, err := repo.ExecCredGitCommand(this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC, this.TargetCredentials.NoSSLVerify, args...); err != nil {
+		this.Log.Out("Failed to prune target-remote\n\n" + err.Error())
+		result.AddBranch("(prune)", ResultFailed, err)
+		return
+	}
+
+	remaining := make([]string, 0, len(pushed))
+	for _, ref := range pushed {
+		if !Contains(candidates, ref) {
+			remaining = append(remaining, ref)
+		}
+	}
+	this.SavePushedRefs(remaining)
+
+	for _, ref := range candidates {
+		result.AddBranch(ref, ResultDeleted, nil)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// RepoURL is a parsed repository url, besides normal urls (https://, ssh://, file://) the scp-like syntax of git is supported ('git@host:path')
+type RepoURL struct {
+	Scheme string // 'ssh' for scp-like urls
+	User   string
+	Host   string // including the port (if any)
+	Path   string
+	SCP    bool
+}
+
+// IsSCPURL returns true for the scp-like syntax of git ('[user@]host:path'), which url.Parse cannot handle
+func IsSCPURL(raw string) bool {
+	if strings.Contains(raw, "://") {
+		return false
+	}
+
+	colon := strings.Index(raw, ":")
+	if colon <= 1 {
+		return false // no colon or a windows drive letter ('C:\...')
+	}
+
+	slash := strings.Index(raw, "/")
+	return slash < 0 || colon < slash
+}
+
+func ParseRepoURL(raw string) (RepoURL, error) {
+	if IsSCPURL(raw) {
+		colon := strings.Index(raw, ":")
+		result := RepoURL{Scheme: "ssh", Host: raw[:colon], Path: raw[colon+1:], SCP: true}
+
+		if at := strings.LastIndex(result.Host, "@"); at >= 0 {
+			result.User = result.Host[:at]
+			result.Host = result.Host[at+1:]
+		}
+
+		if result.Host == "" {
+			return RepoURL{}, errors.New("The url '" + raw + "' has no host")
+		}
+
+		return result, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return RepoURL{}, err
+	}
+
+	return RepoURL{Scheme: strings.ToLower(u.Scheme), User: u.User.Username(), Host: u.Host, Path: u.Path}, nil
+}
+
+// Hostname returns the host without the port
+func (this RepoURL) Hostname() string {
+	return (&url.URL{Host: this.Host}).Hostname()
+}
+
+// IsSSH returns true if git uses the ssh transport for this url
+func (this RepoURL) IsSSH() bool {
+	return this.SCP || this.Scheme == "ssh" || this.Scheme == "git+ssh" || this.Scheme == "ssh+git"
+}
+
+// GetURLHost returns the host (with port) of a repository url, or an empty string if the url cannot be parsed
+func GetURLHost(raw string) string {
+	u, err := ParseRepoURL(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,114 @@
+package main
+
+import "testing"
+
+func TestParseRepoURL(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected RepoURL
+		hostname string
+		ssh      bool
+	}{
+		{"git@github.com:Mikescher/goGitmirror.git", RepoURL{Scheme: "ssh", User: "git", Host: "github.com", Path: "Mikescher/goGitmirror.git", SCP: true}, "github.com", true},
+		{"github.com:Mikescher/goGitmirror.git", RepoURL{Scheme: "ssh", Host: "github.com", Path: "Mikescher/goGitmirror.git", SCP: true}, "github.com", true},
+		{"git@gitlab.example.com:group/sub/repo", RepoURL{Scheme: "ssh", User: "git", Host: "gitlab.example.com", Path: "group/sub/repo", SCP: true}, "gitlab.example.com", true},
+		{"ssh://git@gitlab.example.com:2222/group/repo.git", RepoURL{Scheme: "ssh", User: "git", Host: "gitlab.example.com:2222", Path: "/group/repo.git"}, "gitlab.example.com", true},
+		{"ssh://gitlab.example.com/group/repo.git", RepoURL{Scheme: "ssh", Host: "gitlab.example.com", Path: "/group/repo.git"}, "gitlab.example.com", true},
+		{"git+ssh://git@host/repo.git", RepoURL{Scheme: "git+ssh", User: "git", Host: "host", Path: "/repo.git"}, "host", true},
+		{"https://github.com/Mikescher/goGitmirror.git", RepoURL{Scheme: "https", Host: "github.com", Path: "/Mikescher/goGitmirror.git"}, "github.com", false},
+		{"https://user@git.example.com:8443/repo.git", RepoURL{Scheme: "https", User: "user", Host: "git.example.com:8443", Path: "/repo.git"}, "git.example.com", false},
+		{"HTTP://Git.Example.com/repo", RepoURL{Scheme: "http", Host: "Git.Example.com", Path: "/repo"}, "Git.Example.com", false},
+		{"/srv/git/repo.git", RepoURL{Path: "/srv/git/repo.git"}, "", false},
+		{"./repo:with-colon", RepoURL{Path: "./repo:with-colon"}, "", false},
+	}
+
+	for _, test := range tests {
+		result, err := ParseRepoURL(test.raw)
+		if err != nil {
+			t.Errorf("ParseRepoURL(%q) failed: %v", test.raw, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("ParseRepoURL(%q) = %+v, expected %+v", test.raw, result, test.expected)
+		}
+		if result.Hostname() != test.hostname {
+			t.Errorf("ParseRepoURL(%q).Hostname() = %q, expected %q", test.raw, result.Hostname(), test.hostname)
+		}
+		if result.IsSSH() != test.ssh {
+			t.Errorf("ParseRepoURL(%q).IsSSH() = %v, expected %v", test.raw, result.IsSSH(), test.ssh)
+		}
+	}
+
+	if _, err := ParseRepoURL("@:path"); err == nil {
+		t.Errorf("expected an error for a scp-like url without host")
+	}
+}
+
+func TestIsSCPURL(t *testing.T) {
+	tests := map[string]bool{
+		"git@github.com:user/repo.git": true,
+		"host:repo":                    true,
+		"ssh://git@host:22/repo":       false,
+		"https://host/repo":            false,
+		"C:\\git\\repo":                false,
+		"./dir:name/repo":              false,
+		"/srv/git/repo":                false,
+	}
+
+	for raw, expected := range tests {
+		if IsSCPURL(raw) != expected {
+			t.Errorf("IsSCPURL(%q) != %v", raw, expected)
+		}
+	}
+}
+
+func TestFindCredentialsByURLHost(t *testing.T) {
+	config := GGMConfig{Credentials: []GGCredentials{
+		{Host: "github.com", Username: "github"},
+		{Host: "gitlab.example.com", Username: "gitlab"},
+		{Host: "git.example.com:8443", Username: "gitport"},
+		{Host: "github.com", Username: "byid", ID: "second"},
+	}}
+
+	tests := []struct {
+		url      string
+		id       string
+		username string
+	}{
+		{"git@github.com:Mikescher/goGitmirror.git", "", "github"},
+		{"https://github.com/Mikescher/goGitmirror.git", "", "github"},
+		{"ssh://git@gitlab.example.com:2222/group/repo.git", "", "gitlab"}, // the port is ignored if no credentials match it
+		{"https://git.example.com:8443/repo.git", "", "gitport"},
+		{"https://git.example.com/repo.git", "", ""},
+		{"git@unknown.example.com:repo.git", "", ""},
+		{"git@github.com:Mikescher/goGitmirror.git", "second", "byid"},
+	}
+
+	for _, test := range tests {
+		host := GetURLHost(test.url)
+		cred := config.FindCredentials(host, test.id)
+		if cred.Username != test.username {
+			t.Errorf("FindCredentials(%q, %q) for %s returned '%s', expected '%s'", host, test.id, test.url, cred.Username, test.username)
+		}
+		if test.username != "" && cred.Host != host {
+			t.Errorf("FindCredentials(%q, %q) must set the host of the url, got %q", host, test.id, cred.Host)
+		}
+	}
+}
+
+func TestInitRemoteMatchesCredentialsOfSCPURLs(t *testing.T) {
+	config := GGMConfig{TemporaryPath: t.TempDir(), DivergencePolicy: DivergenceAbort, Credentials: []GGCredentials{
+		{Host: "github.com", Username: "github"},
+		{Host: "gitlab.example.com", Username: "gitlab"},
+	}}
+
+	remote := GGMirror{Source: "git@github.com:Mikescher/goGitmirror.git", Target: "ssh://git@gitlab.example.com:2222/mirror/goGitmirror.git"}
+	config.InitRemote(&remote)
+
+	if remote.SourceCredentials.Username != "github" {
+		t.Errorf("expected the github credentials for the source, got '%s'", remote.SourceCredentials.Username)
+	}
+	if remote.TargetCredentials.Username != "gitlab" {
+		t.Errorf("expected the gitlab credentials for the target, got '%s'", remote.TargetCredentials.Username)
+	}
+}
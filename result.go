@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+)
+
+const (
+	ResultUpdated  = "UPDATED"
+	ResultUpToDate = "UP-TO-DATE"
+	ResultFailed   = "FAILED"
+	ResultSkipped  = "SKIPPED"
+
+	ResultCreated     = "CREATED"
+	ResultMoved       = "MOVED"
+	ResultDeleted     = "DELETED"
+	ResultWouldDelete = "WOULD DELETE"
+
+	ResultUpdatedSource = "UPDATED SRC" // Direction == both: the target was ahead and the source was fast-forwarded
+	ResultDiverged      = "DIVERGED"
+)
+
+type BranchResult struct {
+	Branch string
+	Status string
+	Error  error
+}
+
+// MirrorResult is the outcome of GGMirror.Update, Error is set if the remote failed before any branch was processed
+type MirrorResult struct {
+	Name     string
+	Target   string
+	Error    error
+	Branches []BranchResult
+
+	Submodules []string // the submodule urls found in the mirrored branches (GGMirror.Submodules)
+}
+
+func (this *MirrorResult) AddBranch(branch string, status string, err error) {
+	this.Branches = append(this.Branches, BranchResult{Branch: branch, Status: status, Error: err})
+}
+
+func (this MirrorResult) Failed() bool {
+	if this.Error != nil {
+		return true
+	}
+	for _, branch := range this.Branches {
+		if branch.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// FirstError returns the remote error or the error of the first failed branch
+func (this MirrorResult) FirstError() error {
+	if this.Error != nil {
+		return this.Error
+	}
+	for _, branch := range this.Branches {
+		if branch.Error != nil {
+			return branch.Error
+		}
+	}
+	return nil
+}
+
+// OutputSummary prints one line per remote and branch, followed by the errors of all failed remotes
+// Returns true if at least one remote failed
+func OutputSummary(results []MirrorResult) bool {
+	anyFailed := false
+
+	LOG_OUT("Summary:")
+	LOG_LINESEP()
+	LOG_OUT(" | " + forceStrLen("NAME", STAT_COL_NAME) + "| " + forceStrLen("BRANCH", STAT_COL_BRANCH) + "| " + forceStrLen("RESULT", STAT_COL_RESULT))
+	LOG_OUT("-|-" + strings.Repeat("-", STAT_COL_NAME) + "|-" + strings.Repeat("-", STAT_COL_BRANCH) + "|-" + strings.Repeat("-", STAT_COL_RESULT))
+
+	for _, result := range results {
+		valName := forceStrLen(result.Name, STAT_COL_NAME)
+
+		if result.Error != nil {
+			LOG_OUT("X| " + valName + "| " + forceStrLen("-", STAT_COL_BRANCH) + "| " + forceStrLen(ResultFailed, STAT_COL_RESULT))
+		}
+
+		for _, branch := range result.Branches {
+			marker := " "
+			if branch.Error != nil {
+				marker = "X"
+			}
+			LOG_OUT(marker + "| " + valName + "| " + forceStrLen(branch.Branch, STAT_COL_BRANCH) + "| " + forceStrLen(branch.Status, STAT_COL_RESULT))
+		}
+
+		if result.Failed() {
+			anyFailed = true
+		}
+	}
+
+	LOG_LINESEP()
+
+	for _, result := range results {
+		if result.Error != nil {
+			LOG_OUT("[" + result.Target + "]: " + strings.TrimSpace(result.Error.Error()))
+			LOG_LINESEP()
+		}
+		for _, branch := range result.Branches {
+			if branch.Error != nil {
+				LOG_OUT("[" + result.Target + "] (" + branch.Branch + "): " + strings.TrimSpace(branch.Error.Error()))
+				LOG_LINESEP()
+			}
+		}
+	}
+
+	return anyFailed
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Secrets of the credentials (Password, Token, SSHKeyPassphrase) can be references instead of values:
+//   env:NAME       the environment variable NAME
+//   file:/path     the content of the file (surrounding whitespace is removed)
+//   cmd:command    the first line of the output of the command (executed by sh -c, or cmd /C on windows), e.g. 'cmd:pass show gitlab/mirror'
+// The resolved values may still be encrypted ('aes2:...'), every secret is redacted in the output (see RedactSecrets)
+
+const SECRET_PREFIX_ENV = "env:"
+const SECRET_PREFIX_FILE = "file:"
+const SECRET_PREFIX_CMD = "cmd:"
+
+const SECRET_REDACTED = "***"
+
+const minRedactLength = 4 // shorter secrets are not redacted, they would garble the whole output
+
+var secretValues = make([]string, 0)
+var secretValuesMutex sync.Mutex
+
+// IsSecretReference returns true if the value has one of the reference prefixes
+func IsSecretReference(value string) bool {
+	return strings.HasPrefix(value, SECRET_PREFIX_ENV) || strings.HasPrefix(value, SECRET_PREFIX_FILE) || strings.HasPrefix(value, SECRET_PREFIX_CMD)
+}
+
+// ResolveSecret returns the value of an 'env:', 'file:' or 'cmd:' reference
+func ResolveSecret(value string) (string, error) {
+	if strings.HasPrefix(value, SECRET_PREFIX_ENV) {
+		name := value[len(SECRET_PREFIX_ENV):]
+		result, ok := os.LookupEnv(name)
+		if !ok {
+			return "", errors.New("The environment variable '" + name + "' is not set")
+		}
+		if result == "" {
+			return "", errors.New("The environment variable '" + name + "' is empty")
+		}
+		return result, nil
+	}
+
+	if strings.HasPrefix(value, SECRET_PREFIX_FILE) {
+		path := value[len(SECRET_PREFIX_FILE):]
+		content, err := ioutil.ReadFile(ExpandPath(path))
+		if err != nil {
+			return "", errors.New("Cannot read the file '" + path + "'\n\n" + err.Error())
+		}
+		result := strings.TrimSpace(string(content))
+		if result == "" {
+			return "", errors.New("The file '" + path + "' is empty")
+		}
+		return result, nil
+	}
+
+	if strings.HasPrefix(value, SECRET_PREFIX_CMD) {
+		command := value[len(SECRET_PREFIX_CMD):]
+
+		shell, flag := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, flag = "cmd", "/C"
+		}
+
+		exitcode, stdout, stderr, err := CmdRunEnv("", true, nil, shell, flag, command)
+		if err != nil {
+			return "", errors.New("Cannot execute the command '" + command + "'\n\n" + err.Error())
+		}
+		if exitcode != 0 {
+			return "", errors.New("The command '" + command + "' failed with exit code " + strconv.Itoa(exitcode) + "\n\n" + stderr)
+		}
+		result := strings.TrimSpace(strings.SplitN(strings.TrimLeft(stdout, "\r\n"), "\n", 2)[0])
+		if result == "" {
+			return "", errors.New("The command '" + command + "' printed nothing")
+		}
+		return result, nil
+	}
+
+	return "", errors.New("The value is not a secret reference (must start with env:, file: or cmd:)")
+}
+
+// RegisterSecret adds a value that is replaced by RedactSecrets
+func RegisterSecret(value string) {
+	if len(value) < minRedactLength {
+		return
+	}
+
+	secretValuesMutex.Lock()
+	defer secretValuesMutex.Unlock()
+
+	for _, v := range []string{value, url.QueryEscape(value)} {
+		if !Contains(secretValues, v) {
+			secretValues = append(secretValues, v)
+		}
+	}
+
+	// longer values first, so that a secret which contains another one is not partially leaked
+	sort.SliceStable(secretValues, func(i, j int) bool { return len(secretValues[i]) > len(secretValues[j]) })
+}
+
+// RedactSecrets replaces every registered secret in the text with '***'
+func RedactSecrets(text string) string {
+	secretValuesMutex.Lock()
+	defer secretValuesMutex.Unlock()
+
+	for _, secret := range secretValues {
+		text = strings.Replace(text, secret, SECRET_REDACTED, -1)
+	}
+	return text
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// SSHEnv returns the environment variables that make git (for ssh:// and scp-like urls) use the ssh settings of the credentials
+// Returns nil if no ssh setting is configured, the passphrase of the key is supplied by this binary as SSH_ASKPASS (see ExecAskPass)
+func (this GGCredentials) SSHEnv() []string {
+	if this.SSHKeyPath == "" && this.KnownHostsPath == "" && this.StrictHostKeyChecking == "" {
+		return nil
+	}
+
+	command := []string{"ssh"}
+
+	if this.SSHKeyPath != "" {
+		command = append(command, "-i", shellQuote(this.SSHKeyPath), "-o", "IdentitiesOnly=yes")
+	}
+	if this.KnownHostsPath != "" {
+		command = append(command, "-o", shellQuote("UserKnownHostsFile="+this.KnownHostsPath))
+	}
+	if this.StrictHostKeyChecking != "" {
+		command = append(command, "-o", "StrictHostKeyChecking="+this.StrictHostKeyChecking)
+	}
+
+	env := []string{"GIT_SSH_COMMAND=" + strings.Join(command, " ")}
+
+	if this.SSHKeyPassphrase != "" {
+		env = append(env, "SSH_ASKPASS="+BINARY_PATH, "SSH_ASKPASS_REQUIRE=force", ENV_ASKPASS_CRED+"="+this.UniqID)
+		env = append(env, CryptKeyEnv()...) // ExecAskPass loads the config again
+		if os.Getenv("DISPLAY") == "" {
+			env = append(env, "DISPLAY=:0") // older ssh versions only use SSH_ASKPASS if DISPLAY is set
+		}
+	}
+
+	return env
+}
+
+// IsAskPassCall returns true if this binary was started by ssh as SSH_ASKPASS (the only argument is the prompt)
+func IsAskPassCall() bool {
+	if os.Getenv(ENV_ASKPASS_CRED) == "" || len(os.Args) != 2 {
+		return false
+	}
+	return !Contains([]string{"status", "cron", "single", "add", "backups", "crypt", "credentials"}, strings.ToLower(os.Args[1]))
+}
+
+// shellQuote quotes a value for GIT_SSH_COMMAND (which is executed by a shell)
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", "'\\''", -1) + "'"
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DiscoverSubmodules returns the (absolute) urls of all submodules referenced by the .gitmodules of the given refs
+func (this GGMirror) DiscoverSubmodules(repo GitController, refs []string) []string {
+	result := make([]string, 0)
+
+	for _, ref := range refs {
+		urls, err := repo.ListSubmoduleURLs(ref)
+		if err != nil {
+			this.Log.Out("WARNING: Cannot read .gitmodules of " + ref + "\n\n" + err.Error())
+			continue
+		}
+
+		for _, submodule := range urls {
+			result = AppendIfUniqueCaseInsensitive(result, ResolveSubmoduleURL(this.Source, submodule))
+		}
+	}
+
+	for _, submodule := range result {
+		this.Log.Out("Found submodule " + submodule)
+	}
+
+	return result
+}
+
+// ResolveSubmoduleURL resolves relative submodule urls ('../other.git') against the url of the superproject (like git does)
+func ResolveSubmoduleURL(source string, submodule string) string {
+	if !strings.HasPrefix(submodule, "./") && !strings.HasPrefix(submodule, "../") {
+		return submodule
+	}
+
+	if IsSCPURL(source) {
+		colon := strings.Index(source, ":")
+		return source[:colon+1] + strings.TrimPrefix(path.Join(source[colon+1:], submodule), "/")
+	}
+
+	base, err := url.Parse(strings.TrimRight(source, "/") + "/")
+	if err != nil {
+		return submodule
+	}
+
+	rel, err := url.Parse(submodule)
+	if err != nil {
+		return submodule
+	}
+
+	return base.ResolveReference(rel).String()
+}
+
+// SameRepository compares two repository urls, ignoring case, trailing slashes and the '.git' suffix
+func SameRepository(a string, b string) bool {
+	normalize := func(v string) string {
+		v = strings.ToLower(strings.TrimRight(strings.TrimSpace(v), "/"))
+		return strings.TrimSuffix(v, ".git")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// GetSubmoduleTarget returns the target url for a submodule mirror ('{name}' in SubmoduleTarget is replaced by the repository name)
+func (this GGMirror) GetSubmoduleTarget(submodule string) string {
+	name := strings.TrimSuffix(path.Base(strings.TrimRight(submodule, "/")), ".git")
+	if idx := strings.LastIndex(name, ":"); idx >= 0 {
+		name = name[idx+1:] // scp-style url without path (git@host:repo.git)
+	}
+	return strings.Replace(this.SubmoduleTarget, "{name}", name, -1)
+}
+
+// AddSubmoduleMirrors registers a mirror for every submodule (found by the remotes) that is not yet configured
+// Returns the new mirrors, they are also appended to this.Remote (in-memory only, like the AutoMirror remotes)
+func (this *GGMConfig) AddSubmoduleMirrors(remotes []GGMirror, results [][]MirrorResult) []GGMirror {
+	added := make([]GGMirror, 0)
+
+	for i, parent := range remotes {
+		if !parent.Submodules || parent.SubmoduleTarget == "" {
+			continue
+		}
+
+		for _, result := range results[i] {
+			for _, submodule := range result.Submodules {
+				target := parent.GetSubmoduleTarget(submodule)
+
+				if this.HasSource(submodule) {
+					continue // already mirrored (configured or added by another remote)
+				}
+
+				if SameRepository(submodule, target) {
+					LOG_OUT("WARNING: Skip submodule " + submodule + " (the generated target is the submodule itself)")
+					continue
+				}
+
+				if other := this.FindRemoteByTarget(target); other != nil {
+					LOG_OUT("WARNING: Skip submodule " + submodule + " (the generated target " + target + " is already the target of the remote " + other.Source + ")")
+					continue
+				}
+
+				// InitRemote exits on invalid values, this must not abort the cron run after other remotes were already pushed
+				if !IsValidURL(submodule) || !IsValidURL(target) {
+					LOG_OUT("WARNING: Skip submodule " + submodule + " (the url or the generated target " + target + " is not a valid URL)")
+					continue
+				}
+
+				mirror := GGMirror{
+					ID:                  "submodule:" + submodule,
+					Source:              submodule,
+					Target:              target,
+					TargetCredentialsID: parent.TargetCredentialsID,
+					Force:               parent.Force,
+					Mode:                parent.Mode,
+					Tags:                parent.Tags,
+					LFS:                 parent.LFS,
+					Submodules:          parent.Submodules,
+					SubmoduleTarget:     parent.SubmoduleTarget,
+					TempBaseFolder:      parent.TempBaseFolder,
+				}
+
+				this.InitRemote(&mirror)
+
+				repo := GitController{Folder: ExpandPath(parent.TempBaseFolder), Log: parent.Log}
+				repo.SetSilent()
+				if head, err := repo.GetRemoteHead(mirror.Source, mirror.SourceCredentials, this.CredentialMode, this.AlwaysCleanNetRC); err == nil && head != "" {
+					mirror.PrimaryBranch = head
+				}
+
+				LOG_OUT("Adding submodule mirror " + mirror.Source + " -> " + mirror.Target)
+
+				this.Remote = append(this.Remote, mirror)
+				added = append(added, mirror)
+			}
+		}
+	}
+
+	if len(added) > 0 {
+		LOG_LINESEP()
+	}
+
+	return added
+}
+
+// HasSource returns true if a remote mirrors the repository
+func (this GGMConfig) HasSource(source string) bool {
+	for _, remote := range this.Remote {
+		if SameRepository(remote.Source, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindRemoteByTarget returns the remote that pushes to the target (or nil)
+func (this GGMConfig) FindRemoteByTarget(target string) *GGMirror {
+	for i := range this.Remote {
+		if this.Remote[i].HasTarget(target) {
+			return &this.Remote[i]
+		}
+	}
+	return nil
+}
+
+// OutputSubmoduleReport prints the submodule urls that are not the source of any remote
+func (this GGMConfig) OutputSubmoduleReport(results []MirrorResult) {
+	uncovered := make([]string, 0)
+
+	for _, result := range results {
+		for _, submodule := range result.Submodules {
+			covered := false
+			for _, remote := range this.Remote {
+				if SameRepository(remote.Source, submodule) {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				uncovered = AppendIfUniqueCaseInsensitive(uncovered, submodule)
+			}
+		}
+	}
+
+	sort.Strings(uncovered)
+
+	if len(uncovered) == 0 {
+		LOG_OUT("All submodules are covered by a [[Remote]]")
+		LOG_LINESEP()
+		return
+	}
+
+	LOG_OUT("Submodules not covered by any [[Remote]]:")
+	for _, submodule := range uncovered {
+		LOG_OUT("   > " + submodule)
+	}
+	LOG_LINESEP()
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UpdateBidirectional fast-forwards the side that is behind for every branch (Direction == both)
+// Branches that diverged are reported with their ahead/behind counts and never forced
+func (this GGMirror) UpdateBidirectional(config GGMConfig) []MirrorResult {
+	targets := this.TargetViews()
+	results := this.NewResults(targets)
+	result := &results[0]
+
+	folder := this.GetTargetFolder()
+
+	if !PathIsValid(folder) {
+		EXIT_ERROR("The temporary ggm path is not a valid path '"+folder+"'", EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+
+	err := os.MkdirAll(folder, 0777)
+
+	if err != nil {
+		EXIT_ERROR("Cannot create tmp folder '"+folder+"'", EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+
+	repo := this.NewGitController(folder)
+
+	exists, err := repo.ExistsLocal()
+	if err != nil {
+		return FailAll(results, err)
+	}
+
+	if exists {
+		if err := repo.GarbageCollect(); err != nil {
+			return FailAll(results, err)
+		}
+	}
+
+	if err := repo.CloneOrPull(this.PrimaryBranch, this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC); err != nil {
+		return FailAll(results, err)
+	}
+
+	// the branches of the target are compared with refs/remotes/origin (the source)
+	remote := altTargetRemote(0)
+	if err := repo.FetchBranches(remote, this.Target, this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC); err != nil {
+		return FailAll(results, err)
+	}
+
+	if this.AutoBranchDiscovery {
+		sourceBranches, err := this.DiscoverBranches(repo)
+		if err != nil {
+			return FailAll(results, err)
+		}
+
+		targetBranches, err := repo.ListTrackingBranches(remote)
+		if err != nil {
+			return FailAll(results, err)
+		}
+
+		this.Branches = sourceBranches
+		for _, branch := range this.FilterBranches(targetBranches) {
+			this.Branches = AppendIfUniqueCaseInsensitive(this.Branches, branch)
+		}
+
+		for _, branch := range this.Branches {
+			this.Log.Out("Found branch " + branch)
+		}
+
+		this.Log.Out("")
+	}
+
+	if this.Submodules {
+		sourceRefs := make([]string, 0, len(this.Branches))
+		for _, branch := range this.Branches {
+			sourceRefs = append(sourceRefs, "refs/remotes/origin/"+branch)
+		}
+		result.Submodules = this.DiscoverSubmodules(repo, sourceRefs)
+	}
+
+	lastSynced := this.LoadSyncState()
+	synced := make(map[string]string)
+
+	for _, branch := range this.Branches {
+		status, sha, err := this.SyncBranch(config, repo, remote, branch, lastSynced[branch])
+		if err != nil {
+			this.Log.Out("Failed to sync branch " + branch + "\n\n" + err.Error())
+		}
+		this.Log.Out("")
+
+		if sha != "" {
+			synced[branch] = sha
+		}
+
+		result.AddBranch(branch, status, err)
+
+		if err != nil && config.FailFast {
+			for branch, sha := range synced {
+				lastSynced[branch] = sha
+			}
+			this.SaveSyncState(lastSynced)
+			return results
+		}
+	}
+
+	this.SaveSyncState(synced) // branches that no longer exist on either side are forgotten
+
+	if this.Tags == TagModeAll {
+		this.SyncTags(config, repo, result)
+	}
+
+	return results
+}
+
+// SyncBranch compares the branch of the source (origin) and the target (remote) and pushes the newer one to the other side
+// lastSynced is the SHA of the branch after the last successful sync (empty if it was never synced), a branch that is missing on one side
+// is only created there if it was never synced, otherwise it was deleted on that side and is reported instead of being resurrected
+// Returns the SHA that is recorded for the next run (the state both sides have now, or lastSynced if they are not in sync)
+func (this GGMirror) SyncBranch(config GGMConfig, repo GitController, remote string, branch string, lastSynced string) (string, string, error) {
+	shaSource := repo.GetRefHash("refs/remotes/origin/" + branch)
+	shaTarget := repo.GetRefHash("refs/remotes/" + remote + "/" + branch)
+
+	followTags := this.Tags == TagModeFollow
+
+	if shaSource == "" && shaTarget == "" {
+		return ResultFailed, "", errors.New("The branch " + branch + " does not exist in source-remote or target-remote")
+	}
+
+	if shaSource == shaTarget {
+		this.Log.Out("Skip branch " + branch + " (up-to-date with SHA " + shaSource[0:8] + ")")
+		return ResultUpToDate, shaSource, nil
+	}
+
+	if shaTarget == "" {
+		if lastSynced != "" {
+			return this.reportDeletedBranch(branch, "target", "source", shaSource, lastSynced)
+		}
+		this.Log.Out("Branch " + branch + " does not exist in target-remote - pushing it from source-remote")
+		return syncResult(ResultUpdated, shaSource, lastSynced, repo.PushCommit(shaSource, branch, this.Target, this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC, followTags))
+	}
+
+	if shaSource == "" {
+		if lastSynced != "" {
+			return this.reportDeletedBranch(branch, "source", "target", shaTarget, lastSynced)
+		}
+		this.Log.Out("Branch " + branch + " does not exist in source-remote - pushing it from target-remote")
+		return syncResult(ResultUpdatedSource, shaTarget, lastSynced, repo.PushCommit(shaTarget, branch, this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC, followTags))
+	}
+
+	targetBehind, err := repo.IsAncestor(shaTarget, shaSource)
+	if err != nil {
+		return ResultFailed, lastSynced, err
+	}
+	if targetBehind {
+		this.Log.Out("Branch " + branch + " is behind in target-remote - fast-forwarding it to " + shaSource[0:8])
+		return syncResult(ResultUpdated, shaSource, lastSynced, repo.PushCommit(shaSource, branch, this.Target, this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC, followTags))
+	}
+
+	sourceBehind, err := repo.IsAncestor(shaSource, shaTarget)
+	if err != nil {
+		return ResultFailed, lastSynced, err
+	}
+	if sourceBehind {
+		this.Log.Out("Branch " + branch + " is behind in source-remote - fast-forwarding it to " + shaTarget[0:8])
+		return syncResult(ResultUpdatedSource, shaTarget, lastSynced, repo.PushCommit(shaTarget, branch, this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC, followTags))
+	}
+
+	ahead, behind, err := repo.CountDivergence(shaSource, shaTarget)
+	if err != nil {
+		return ResultFailed, lastSynced, err
+	}
+
+	return ResultDiverged, lastSynced, errors.New("The branch " + branch + " has diverged: the source is " + strconv.Itoa(ahead) + " commit(s) ahead and " + strconv.Itoa(behind) + " commit(s) behind the target (resolve the conflict manually, it is never forced)")
+}
+
+// reportDeletedBranch handles a branch that was synced before, but no longer exists on one side (deleted) - it is never recreated
+func (this GGMirror) reportDeletedBranch(branch string, deletedIn string, existsIn string, sha string, lastSynced string) (string, string, error) {
+	if sha != lastSynced {
+		return ResultDiverged, lastSynced, errors.New("The branch " + branch + " was deleted in the " + deletedIn + ", but has new commits in the " + existsIn + " since the last sync (resolve the conflict manually)")
+	}
+
+	this.Log.Out("Skip branch " + branch + " (deleted in " + deletedIn + "-remote since the last sync, it is not recreated - delete it in " + existsIn + "-remote too)")
+	return ResultSkipped, lastSynced, nil
+}
+
+func syncResult(status string, sha string, lastSynced string, err error) (string, string, error) {
+	if err != nil {
+		return ResultFailed, lastSynced, err
+	}
+	return status, sha, nil
+}
+
+// GetSyncStatePath returns the file that records the SHA of every branch after the last sync (Direction == both), next to the .pushed file
+func (this GGMirror) GetSyncStatePath() string {
+	return filepath.Join(ExpandPath(this.TempBaseFolder), TEMPFOLDERNAME, GetFolderName(this.Target)+".synced")
+}
+
+// LoadSyncState returns the branches and their SHA after the last sync (empty if the file does not exist)
+func (this GGMirror) LoadSyncState() map[string]string {
+	result := make(map[string]string)
+
+	content, err := ioutil.ReadFile(this.GetSyncStatePath())
+	if err != nil {
+		return result
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		cols := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(cols) == 2 {
+			result[cols[1]] = cols[0]
+		}
+	}
+	return result
+}
+
+func (this GGMirror) SaveSyncState(state map[string]string) {
+	branches := make([]string, 0, len(state))
+	for branch := range state {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+
+	lines := make([]string, 0, len(branches))
+	for _, branch := range branches {
+		lines = append(lines, state[branch]+" "+branch)
+	}
+
+	err := ioutil.WriteFile(this.GetSyncStatePath(), []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	if err != nil {
+		EXIT_ERROR("Cannot write file '"+this.GetSyncStatePath()+"'\n\n"+err.Error(), EXIT_FILESYSTEM_ACCESS_ERROR)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type TagMode string
+
+const (
+	TagModeNone     TagMode = "none"      // never push tags
+	TagModeFollow   TagMode = "follow"    // push the tags reachable from the pushed branches (default)
+	TagModeAll      TagMode = "all"       // push all new tags, moved tags are reported but not updated
+	TagModeAllPrune TagMode = "all+prune" // like all, also delete tags from the target that were deleted in the source
+	TagModeAllForce TagMode = "all+force" // like all+prune, also force-update moved tags
+)
+
+// SyncTags pushes the tags of the source to the target (independent of the branches) and reports created, moved and deleted tags
+func (this GGMirror) SyncTags(config GGMConfig, repo GitController, result *MirrorResult) {
+	this.Log.Out("Synchronizing tags (" + string(this.Tags) + ")")
+
+	sourceTags, err := repo.ListRemoteTags(this.Source, this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
+	if err != nil {
+		result.AddBranch("(tags)", ResultFailed, err)
+		return
+	}
+
+	targetTags, err := repo.ListRemoteTags(this.Target, this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC)
+	if err != nil {
+		result.AddBranch("(tags)", ResultFailed, err)
+		return
+	}
+
+	// the local tags must match the source exactly, they could be stale or moved (and we push them by name)
+	if _, err := repo.ExecCredGitCommand(this.SourceCredentials, config.CredentialMode, config.AlwaysCleanNetRC, this.SourceCredentials.NoSSLVerify, "fetch", "--prune", "--no-tags", this.Source, "+refs/tags/*:refs/tags/*"); err != nil {
+		result.AddBranch("(tags)", ResultFailed, err)
+		return
+	}
+
+	refspecs := make([]string, 0)
+	statuses := make(map[string]string)
+
+	for _, tag := range sortedKeys(sourceTags) {
+		ref := "refs/tags/" + tag
+		targetSha, exists := targetTags[tag]
+
+		if !exists {
+			this.Log.Out("Tag " + tag + " is missing in target-remote")
+			refspecs = append(refspecs, ref+":"+ref)
+			statuses[ref] = ResultCreated
+		} else if targetSha != sourceTags[tag] {
+			if this.Tags == TagModeAllForce {
+				this.Log.Out("Tag " + tag + " was moved in source-remote")
+				refspecs = append(refspecs, "+"+ref+":"+ref)
+				statuses[ref] = ResultMoved
+			} else {
+				this.Log.Out("Tag " + tag + " was moved in source-remote (not updated, use Tags = all+force)")
+				result.AddBranch(ref, ResultSkipped, nil)
+			}
+		}
+	}
+
+	if this.Tags == TagModeAllPrune || this.Tags == TagModeAllForce {
+		deleted := make([]string, 0)
+		for _, tag := range sortedKeys(targetTags) {
+			if _, exists := sourceTags[tag]; !exists {
+				deleted = append(deleted, "refs/tags/"+tag)
+			}
+		}
+
+		// deleted tags are limited by PruneLimit just like the refs of PruneTarget
+		overLimit := len(deleted) > 0 && *this.PruneLimit >= 0 && len(deleted) > *this.PruneLimit
+
+		if config.PruneDryRun {
+			for _, ref := range deleted {
+				this.Log.Out("Would delete tag " + strings.TrimPrefix(ref, "refs/tags/") + " from target-remote (dry-run)")
+				result.AddBranch(ref, ResultWouldDelete, nil)
+			}
+			if overLimit {
+				this.Log.Out("A real run would delete no tags, these are more than PruneLimit = " + strconv.Itoa(*this.PruneLimit) + " tags")
+			}
+		} else if overLimit {
+			err := errors.New("Refusing to delete " + strconv.Itoa(len(deleted)) + " tags from the target (PruneLimit = " + strconv.Itoa(*this.PruneLimit) + "): " + strings.Join(deleted, ", "))
+			this.Log.Out(err.Error())
+			result.AddBranch("(tags)", ResultFailed, err)
+		} else {
+			for _, ref := range deleted {
+				this.Log.Out("Tag " + strings.TrimPrefix(ref, "refs/tags/") + " was deleted in source-remote")
+				refspecs = append(refspecs, ":"+ref)
+				statuses[ref] = ResultDeleted
+			}
+		}
+	}
+
+	if len(refspecs) == 0 {
+		this.Log.Out("No tag changes to push to target-remote")
+		return
+	}
+
+	this.Log.Out("Pushing " + strconv.Itoa(len(refspecs)) + " tag changes to target-remote")
+
+	args := append([]string{"push", "--porcelain", this.Target}, refspecs...)
+	stdout, err := repo.ExecCredGitCommand(this.TargetCredentials, config.CredentialMode, config.AlwaysCleanNetRC, this.TargetCredentials.NoSSLVerify, args...)
+	this.Log.Out(stdout)
+
+	// the push is not atomic, so we report every tag that was changed (even if the command failed)
+	for _, ref := range ParsePushPorcelain(stdout) {
+		if status, ok := statuses[ref]; ok {
+			result.AddBranch(ref, status, nil)
+		}
+	}
+
+	if err != nil {
+		this.Log.Out("Failed to push tags\n\n" + err.Error())
+		result.AddBranch("(tags)", ResultFailed, err)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
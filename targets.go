@@ -0,0 +1,89 @@
+package main
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// GGMirrorTarget is one entry of GGMirror.Targets, a remote with a single Target is normalized to one entry
+type GGMirrorTarget struct {
+	URL string
+
+	CredentialsID string        // if set, use these credentials (by-id)
+	Credentials   GGCredentials // normally not set via TOML, but auto assigned based on host
+
+	Force  bool           // force push to this target (in addition to GGMirror.Force)
+	RefMap []GGRefMapping // if set used instead of GGMirror.RefMap
+}
+
+// TargetViews returns one copy of the remote per target, with Target, TargetCredentials, Force and RefMap of that target
+// The local folder stays the same for all copies (see GetTargetFolder)
+func (this GGMirror) TargetViews() []GGMirror {
+	if len(this.Targets) == 0 {
+		return []GGMirror{this}
+	}
+
+	result := make([]GGMirror, 0, len(this.Targets))
+	for _, target := range this.Targets {
+		view := this
+		view.Target = target.URL
+		view.TargetCredentials = target.Credentials
+		view.Force = this.Force || target.Force
+		if len(target.RefMap) > 0 {
+			view.RefMap = target.RefMap
+		}
+		result = append(result, view)
+	}
+	return result
+}
+
+// HasTarget returns true if one of the targets has the given url (case-insensitive)
+func (this GGMirror) HasTarget(target string) bool {
+	if strings.EqualFold(this.Target, target) {
+		return true
+	}
+	for _, t := range this.Targets {
+		if strings.EqualFold(t.URL, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewResults returns an empty MirrorResult for every target
+func (this GGMirror) NewResults(targets []GGMirror) []MirrorResult {
+	results := make([]MirrorResult, 0, len(targets))
+	for _, target := range targets {
+		results = append(results, MirrorResult{Name: target.GetShortName(), Target: target.Target})
+	}
+	return results
+}
+
+// FailAll sets the error of every result (used if the source could not be fetched)
+func FailAll(results []MirrorResult, err error) []MirrorResult {
+	for i := range results {
+		results[i].Error = err
+	}
+	return results
+}
+
+// altTargetRemote returns the name of the remote used by FastUpdateCheck for the i-th target
+func altTargetRemote(i int) string {
+	if i == 0 {
+		return "orig-target"
+	}
+	return "orig-target-" + strconv.Itoa(i+1)
+}
+
+// targetHost returns the host of the target, used to distinguish the targets of one remote in the output
+func (this GGMirror) targetHost() string {
+	urlTarget, err := ParseRepoURL(this.Target)
+	if err != nil {
+		return this.Target
+	}
+	if urlTarget.Host == "" {
+		return path.Base(urlTarget.Path) // local repositories (file://)
+	}
+	return urlTarget.Host
+}
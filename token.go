@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type TokenStyle string
+
+const (
+	TokenStyleGitlab    TokenStyle = "gitlab"    // username 'oauth2', the token as password
+	TokenStyleGithub    TokenStyle = "github"    // any username (default == x-access-token), the token as password
+	TokenStyleBitbucket TokenStyle = "bitbucket" // username 'x-token-auth', the token as password
+	TokenStyleBearer    TokenStyle = "bearer"    // 'Authorization: Bearer <token>' header (http.extraHeader), no username/password
+)
+
+// DefaultTokenStyle returns the token style of the well-known forges, or an empty string for other hosts
+func DefaultTokenStyle(host string) TokenStyle {
+	switch strings.ToLower((RepoURL{Host: host}).Hostname()) {
+	case "gitlab.com":
+		return TokenStyleGitlab
+	case "github.com":
+		return TokenStyleGithub
+	case "bitbucket.org":
+		return TokenStyleBitbucket
+	}
+	return ""
+}
+
+// ApplyToken translates Token into Username/Password (or keeps it for the bearer header), called by LoadFromFile after decryption
+func (this *GGCredentials) ApplyToken() error {
+	if this.Token == "" {
+		if this.TokenStyle != "" {
+			return errors.New("TokenStyle is set but Token is empty")
+		}
+		return nil
+	}
+
+	if this.Password != "" {
+		return errors.New("Only one of Password and Token can be set")
+	}
+
+	if this.TokenStyle == "" {
+		this.TokenStyle = DefaultTokenStyle(this.Host)
+	}
+
+	this.TokenStyle = TokenStyle(strings.ToLower(string(this.TokenStyle)))
+
+	switch this.TokenStyle {
+	case TokenStyleGitlab:
+		this.Username = "oauth2"
+		this.Password = this.Token
+	case TokenStyleGithub:
+		if this.Username == "" {
+			this.Username = "x-access-token"
+		}
+		this.Password = this.Token
+	case TokenStyleBitbucket:
+		this.Username = "x-token-auth"
+		this.Password = this.Token
+	case TokenStyleBearer:
+		// sent as header, see TokenEnv
+	case "":
+		return errors.New("TokenStyle must be set for hosts other than gitlab.com, github.com and bitbucket.org (must be one of [gitlab, github, bitbucket, bearer])")
+	default:
+		return errors.New("Invalid TokenStyle '" + string(this.TokenStyle) + "' (must be one of [gitlab, github, bitbucket, bearer])")
+	}
+
+	return nil
+}
+
+// IsBearer returns true if the credentials authenticate with an 'Authorization: Bearer' header
+func (this GGCredentials) IsBearer() bool {
+	return this.TokenStyle == TokenStyleBearer && this.Token != ""
+}
+
+// TokenEnv returns the environment variables that make git send the bearer token as http.<url>.extraHeader
+// The header is scoped to the host of the credentials, so a command that contacts multiple remotes (e.g. 'fetch --all') never sends it to another host
+// It is passed with GIT_CONFIG_COUNT (git >= 2.31) instead of '-c', so it never appears in the logged command line
+func (this GGCredentials) TokenEnv() []string {
+	if !this.IsBearer() || this.Host == "" {
+		return nil
+	}
+
+	header := "Authorization: Bearer " + this.Token
+
+	return []string{
+		"GIT_CONFIG_COUNT=2",
+		"GIT_CONFIG_KEY_0=http.https://" + this.Host + "/.extraHeader",
+		"GIT_CONFIG_VALUE_0=" + header,
+		"GIT_CONFIG_KEY_1=http.http://" + this.Host + "/.extraHeader",
+		"GIT_CONFIG_VALUE_1=" + header,
+	}
+}
+
+// GitEnv returns all environment variables for git commands that use these credentials (ssh settings and bearer token)
+func (this GGCredentials) GitEnv() []string {
+	return append(this.SSHEnv(), this.TokenEnv()...)
+}
+
+// AuthorizeRequest sets the bearer header or the basic-auth of a forge API request
+func (this GGCredentials) AuthorizeRequest(req *http.Request) {
+	if this.IsBearer() {
+		req.Header.Set("Authorization", "Bearer "+this.Token)
+	} else if !IsEmpty(this.Username) && !IsEmpty(this.Password) {
+		req.SetBasicAuth(this.Username, this.Password)
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"github.com/willf/pad"
 	"net/url"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"os"
 	"os/exec"
@@ -98,7 +100,7 @@ func Contains(slice []string, item string) bool {
 
 func ParamIsSet(longArg string) bool {
 	for _, s := range os.Args[1:] {
-		if s[0:2] == "--" {
+		if strings.HasPrefix(s, "--") {
 			if strings.ToLower(s[2:]) == strings.ToLower(longArg) {
 				return true
 			}
@@ -110,11 +112,11 @@ func ParamIsSet(longArg string) bool {
 
 func ParamIsSet2(longArg string, shortArg string) bool {
 	for _, s := range os.Args[1:] {
-		if s[0:2] == "--" {
+		if strings.HasPrefix(s, "--") {
 			if strings.ToLower(s[2:]) == strings.ToLower(longArg) {
 				return true
 			}
-		} else if s[0:1] == "-" {
+		} else if strings.HasPrefix(s, "-") {
 			if strings.ToLower(s[1:]) == strings.ToLower(shortArg) {
 				return true
 			}
@@ -124,8 +126,29 @@ func ParamIsSet2(longArg string, shortArg string) bool {
 	return false
 }
 
+// ParamValue returns the value of a '--arg value' or '--arg=value' parameter
+// If the parameter is set without a value (last argument or followed by another '--' parameter) the value is empty, the callers must reject that
+func ParamValue(longArg string) (string, bool) {
+	for i, s := range os.Args[1:] {
+		if !strings.HasPrefix(s, "--") {
+			continue
+		}
+		if strings.ToLower(s[2:]) == strings.ToLower(longArg) {
+			if i+2 < len(os.Args) && !strings.HasPrefix(os.Args[i+2], "--") {
+				return os.Args[i+2], true
+			}
+			return "", true
+		}
+		if strings.HasPrefix(strings.ToLower(s[2:]), strings.ToLower(longArg)+"=") {
+			return s[len(longArg)+3:], true
+		}
+	}
+
+	return "", false
+}
+
 func IsValidURL(uri string) bool {
-	_, err := url.Parse(uri)
+	_, err := ParseRepoURL(uri)
 	if err != nil {
 		return false
 	}
@@ -146,6 +169,19 @@ func ExpandPath(path string) string {
 	return path
 }
 
+// ParseAge parses a go duration, with the additional support of a 'd' (days) suffix
+func ParseAge(str string) (time.Duration, error) {
+	str = strings.TrimSpace(str)
+	if strings.HasSuffix(str, "d") {
+		days, err := strconv.Atoi(str[:len(str)-1])
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(str)
+}
+
 func InRange(needle rune, start rune, end rune) bool {
 	return needle >= start && needle <= end
 }
@@ -172,7 +208,10 @@ func NormalizeStringToFilePath(str string) string {
 }
 
 func EXIT_ERROR(msg string, code int) {
-	os.Stderr.WriteString(msg + "\n")
+	flushActiveLogBuffers()
+
+	logMutex.Lock()
+	os.Stderr.WriteString(RedactSecrets(msg) + "\n")
 
 	ExitNetRCBlock(false)
 
@@ -180,11 +219,14 @@ func EXIT_ERROR(msg string, code int) {
 }
 
 func LOG_OUT(msg string) {
+	msg = RedactSecrets(msg)
+	logMutex.Lock()
 	os.Stdout.WriteString(msg + "\n")
+	logMutex.Unlock()
 }
 
 func LOG_LINESEP() {
-	os.Stdout.WriteString("\n")
+	LOG_OUT("")
 }
 
 func PathIsValid(path string) bool {
@@ -232,6 +274,11 @@ func CleanFolder(dir string) error {
 }
 
 func CmdRun(folder string, silent bool, command string, args ...string) (int, string, string, error) {
+	return CmdRunEnv(folder, silent, nil, command, args...)
+}
+
+// CmdRunEnv is CmdRun with additional environment variables ('KEY=value')
+func CmdRunEnv(folder string, silent bool, env []string, command string, args ...string) (int, string, string, error) {
 
 	//IF DEBUG
 	if !silent {
@@ -250,6 +297,9 @@ func CmdRun(folder string, silent bool, command string, args ...string) (int, st
 	cmd := exec.Command(binary, args...)
 
 	cmd.Dir = folder
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	cmd.Stdout = wout
 	cmd.Stderr = werr
 
@@ -298,6 +348,15 @@ func Join(sep string, arr []string) string {
 	return buffer.String()
 }
 
+func ContainsCaseInsensitive(slice []string, item string) bool {
+	for _, ele := range slice {
+		if strings.EqualFold(ele, item) {
+			return true
+		}
+	}
+	return false
+}
+
 func AppendIfUniqueCaseInsensitive(slice []string, i string) []string {
 	for _, ele := range slice {
 		if strings.EqualFold(ele, i) {